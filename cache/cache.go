@@ -12,6 +12,11 @@ import (
 
 var ErrNotFound = errors.New("object not found in cache")
 
+// Flusher is implemented by caches that buffer writes. Flush is called when the ShardManager shuts down.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
 type Cache interface {
 	Options() CacheOptions
 
@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff produces exponentially increasing delays, with jitter so that shards do not reconnect in lockstep
+type backoff struct {
+	min, max time.Duration
+	current  time.Duration
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{
+		min: min,
+		max: max,
+	}
+}
+
+func (b *backoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+	} else {
+		b.current *= 2
+		if b.current > b.max {
+			b.current = b.max
+		}
+	}
+
+	// pick a delay between half and all of the current interval
+	half := b.current / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
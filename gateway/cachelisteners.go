@@ -32,7 +32,7 @@ func RegisterCacheListeners(sm *ShardManager) {
 func readyListener(s *Shard, e *events.Ready) {
 	logrus.Infof("shard %d: received ready", s.ShardId)
 
-	s.sessionId = e.SessionId
+	s.setSession(e.SessionId, e.ResumeGatewayUrl)
 
 	s.Cache.StoreSelf(context.Background(), e.User)
 }
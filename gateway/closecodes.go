@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"nhooyr.io/websocket"
+)
+
+type CloseCode int
+
+const (
+	CloseUnknownError         CloseCode = 4000
+	CloseUnknownOpcode        CloseCode = 4001
+	CloseDecodeError          CloseCode = 4002
+	CloseNotAuthenticated     CloseCode = 4003
+	CloseAuthenticationFailed CloseCode = 4004
+	CloseAlreadyAuthenticated CloseCode = 4005
+	CloseInvalidSequence      CloseCode = 4007
+	CloseRateLimited          CloseCode = 4008
+	CloseSessionTimedOut      CloseCode = 4009
+	CloseInvalidShard         CloseCode = 4010
+	CloseShardingRequired     CloseCode = 4011
+	CloseInvalidApiVersion    CloseCode = 4012
+	CloseInvalidIntents       CloseCode = 4013
+	CloseDisallowedIntents    CloseCode = 4014
+)
+
+// Fatal returns true if reconnecting after receiving the close code would fail again
+func (c CloseCode) Fatal() bool {
+	switch c {
+	case CloseAuthenticationFailed, CloseInvalidShard, CloseShardingRequired, CloseInvalidApiVersion,
+		CloseInvalidIntents, CloseDisallowedIntents:
+		return true
+	default:
+		return false
+	}
+}
+
+// Resumable returns true if the session may be resumed after receiving the close code, rather than re-identifying
+func (c CloseCode) Resumable() bool {
+	if c.Fatal() {
+		return false
+	}
+
+	switch c {
+	case CloseInvalidSequence, CloseSessionTimedOut:
+		return false
+	default:
+		return true
+	}
+}
+
+// CloseError is returned when Discord closes the gateway connection with a close code
+type CloseError struct {
+	Code   CloseCode
+	Reason string
+}
+
+func (e CloseError) Error() string {
+	return fmt.Sprintf("gateway closed with code %d: %s", e.Code, e.Reason)
+}
+
+// closeErrorFrom returns the close code and reason of a connection closed by Discord, if err was caused by a close frame
+func closeErrorFrom(err error) (CloseError, bool) {
+	var closeErr websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return CloseError{}, false
+	}
+
+	return CloseError{
+		Code:   CloseCode(closeErr.Code),
+		Reason: closeErr.Reason,
+	}, true
+}
@@ -0,0 +1,213 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rxdn/gdl/gateway/cluster"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+const DefaultClusterHeartbeatInterval = time.Second * 5
+
+// ClusterOptions enables cluster mode, in which the shards are split between every worker that is registered with the
+// coordinator, and are handed over between workers as they join and leave. ShardCount.Total must be set, and must be
+// the same for every worker, whereas ShardCount.Lowest and ShardCount.Highest are ignored.
+//
+// Workers should share a SessionStore, so that shards resume their sessions when they are handed over, rather than
+// identifying again, and a ratelimit.RedisStore, so that identifies are coordinated between workers.
+type ClusterOptions struct {
+	Coordinator       cluster.Coordinator
+	WorkerId          string        // must be unique within the cluster. defaults to the hostname and process ID
+	HeartbeatInterval time.Duration // how often to join the coordinator and rebalance. must be well under the coordinator's TTL. defaults to 5 seconds
+}
+
+type clusterWorker struct {
+	sm      *ShardManager
+	options ClusterOptions
+
+	assignedLock    sync.Mutex
+	lowest, highest int // the shards assigned to this worker by the last rebalance
+}
+
+func newClusterWorker(sm *ShardManager, options ClusterOptions) *clusterWorker {
+	if options.WorkerId == "" {
+		hostname, _ := os.Hostname()
+		options.WorkerId = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	if options.HeartbeatInterval == 0 {
+		options.HeartbeatInterval = DefaultClusterHeartbeatInterval
+	}
+
+	if sm.ShardOptions.SessionStore == nil {
+		logrus.Warn("Cluster mode is enabled without a SessionStore, so shards will identify again when handed over")
+	}
+
+	if _, ok := sm.ShardOptions.RateLimitStore.(*ratelimit.MemoryStore); ok {
+		logrus.Warn("Cluster mode is enabled with a MemoryStore, so identifies are not coordinated between workers")
+	}
+
+	return &clusterWorker{
+		sm:      sm,
+		options: options,
+	}
+}
+
+func (w *clusterWorker) run() {
+	ticker := time.NewTicker(w.options.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		w.rebalance()
+
+		select {
+		case <-w.sm.ctx.Done():
+			return // Shutdown releases the shards once their sessions have been saved
+		case <-ticker.C:
+		}
+	}
+}
+
+// rebalance refreshes the worker's registration, hands off the shards that are no longer assigned to this worker, stops
+// the shards that have been claimed by another worker, and starts the shards that are newly assigned to it once their
+// previous owners have released them
+func (w *clusterWorker) rebalance() {
+	ctx, cancel := context.WithTimeout(w.sm.ctx, w.options.HeartbeatInterval)
+	defer cancel()
+
+	coordinator := w.options.Coordinator
+	if err := coordinator.Join(ctx, w.options.WorkerId); err != nil {
+		logrus.Warnf("Error whilst joining cluster: %s", err.Error())
+		return
+	}
+
+	workers, err := coordinator.Workers(ctx)
+	if err != nil {
+		logrus.Warnf("Error whilst fetching cluster workers: %s", err.Error())
+		return
+	}
+
+	lowest, highest := cluster.Assign(w.sm.shardCount().Total, workers, w.options.WorkerId)
+
+	w.assignedLock.Lock()
+	w.lowest, w.highest = lowest, highest
+	w.assignedLock.Unlock()
+
+	// If shards were handed off, those that remain may all be ready
+	defer w.sm.shardReady()
+
+	// Hand off shards first, so that their new owners can claim them as soon as possible
+	running := make(map[int]struct{})
+	for _, shard := range w.sm.ShardList() {
+		if shard.ShardId < lowest || shard.ShardId >= highest {
+			w.handOff(ctx, shard)
+			continue
+		}
+
+		// If this worker missed enough heartbeats to be considered dead, another worker may have claimed the shard
+		if err := coordinator.Claim(ctx, w.options.WorkerId, shard.ShardId); err != nil {
+			if errors.Is(err, cluster.ErrClaimed) {
+				w.lose(shard)
+			} else {
+				logrus.Warnf("shard %d: Error whilst renewing claim: %s", shard.ShardId, err.Error())
+				running[shard.ShardId] = struct{}{}
+			}
+
+			continue
+		}
+
+		running[shard.ShardId] = struct{}{}
+	}
+
+	for shardId := lowest; shardId < highest; shardId++ {
+		if _, ok := running[shardId]; ok {
+			continue
+		}
+
+		if err := coordinator.Claim(ctx, w.options.WorkerId, shardId); err != nil {
+			if errors.Is(err, cluster.ErrClaimed) {
+				logrus.Infof("shard %d: Waiting for the previous owner to hand off the shard", shardId)
+			} else {
+				logrus.Warnf("shard %d: Error whilst claiming shard: %s", shardId, err.Error())
+			}
+
+			continue
+		}
+
+		logrus.Infof("shard %d: Assigned to worker %s", shardId, w.options.WorkerId)
+
+		shard := NewShard(w.sm, w.sm.Token, shardId)
+		w.sm.setShards(func(shards map[int]*Shard) {
+			shards[shardId] = &shard
+		})
+
+		w.sm.connectShard(&shard)
+	}
+}
+
+func (w *clusterWorker) assigned() (lowest, highest int) {
+	w.assignedLock.Lock()
+	defer w.assignedLock.Unlock()
+	return w.lowest, w.highest
+}
+
+// handOff stops a shard and saves its session, before releasing it so that its new owner can resume the session
+func (w *clusterWorker) handOff(ctx context.Context, shard *Shard) {
+	logrus.Infof("shard %d: Handing off to another worker", shard.ShardId)
+
+	w.sm.setShards(func(shards map[int]*Shard) {
+		delete(shards, shard.ShardId)
+	})
+
+	shard.stop()
+
+	if err := shard.saveSession(ctx); err != nil {
+		logrus.Warnf("shard %d: Error whilst saving session: %s", shard.ShardId, err.Error())
+	}
+
+	if err := w.options.Coordinator.Release(ctx, w.options.WorkerId, shard.ShardId); err != nil {
+		logrus.Warnf("shard %d: Error whilst releasing shard: %s", shard.ShardId, err.Error())
+	}
+}
+
+// lose stops a shard that has been claimed by another worker. Its session is neither saved nor released, as the new
+// owner may already have resumed it.
+func (w *clusterWorker) lose(shard *Shard) {
+	logrus.Warnf("shard %d: Claimed by another worker, stopping", shard.ShardId)
+
+	w.sm.setShards(func(shards map[int]*Shard) {
+		delete(shards, shard.ShardId)
+	})
+
+	shard.stop()
+}
+
+// leave is called by Shutdown once the shards have stopped and their sessions have been saved
+func (w *clusterWorker) leave(ctx context.Context, shards []*Shard) error {
+	var errs []error
+	for _, shard := range shards {
+		if err := w.options.Coordinator.Release(ctx, w.options.WorkerId, shard.ShardId); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := w.options.Coordinator.Leave(ctx, w.options.WorkerId); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// stop closes the connection in a way that allows the session to be resumed, and stops the shard from reconnecting
+func (s *Shard) stop() {
+	s.cancel()
+
+	if err := s.Kill(); err != nil {
+		logrus.Warnf("shard %d: Error whilst closing connection: %s", s.ShardId, err.Error())
+	}
+}
@@ -0,0 +1,48 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// ErrClaimed is returned by Claim if the shard is owned by another worker that is still alive
+var ErrClaimed = errors.New("shard is claimed by another worker")
+
+// Coordinator tracks the workers in a cluster and which worker owns each shard. Workers that have not called Join
+// within the coordinator's TTL are considered dead, and their shards may be claimed by other workers.
+type Coordinator interface {
+	// Join registers the worker, or refreshes its registration. Workers call it periodically to stay alive.
+	Join(ctx context.Context, workerId string) error
+
+	// Leave removes the worker, so that its shards are reassigned without waiting for it to expire
+	Leave(ctx context.Context, workerId string) error
+
+	// Workers returns the IDs of the workers that are alive, in ascending order
+	Workers(ctx context.Context) ([]string, error)
+
+	// Claim takes ownership of a shard. It returns ErrClaimed if another worker that is alive owns the shard, so that a
+	// shard is only handed over once its previous owner has saved its session and released it, or has died.
+	Claim(ctx context.Context, workerId string, shardId int) error
+
+	// Release gives up ownership of a shard. It does nothing if the worker does not own the shard.
+	Release(ctx context.Context, workerId string, shardId int) error
+}
+
+// Assign splits [0, totalShards) into contiguous ranges, one per worker, and returns the range [lowest, highest) of
+// workerId. Every worker that sees the same set of workers computes the same ranges. If workerId is not one of the
+// workers, the range is empty.
+func Assign(totalShards int, workers []string, workerId string) (lowest, highest int) {
+	sorted := make([]string, len(workers))
+	copy(sorted, workers)
+	sort.Strings(sorted)
+
+	index := sort.SearchStrings(sorted, workerId)
+	if index == len(sorted) || sorted[index] != workerId {
+		return 0, 0
+	}
+
+	lowest = totalShards * index / len(sorted)
+	highest = totalShards * (index + 1) / len(sorted)
+	return
+}
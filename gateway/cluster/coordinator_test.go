@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssign(t *testing.T) {
+	workers := []string{"c", "a", "b"}
+
+	var covered []int
+	for _, workerId := range []string{"a", "b", "c"} {
+		lowest, highest := Assign(10, workers, workerId)
+		for shardId := lowest; shardId < highest; shardId++ {
+			covered = append(covered, shardId)
+		}
+	}
+
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, covered)
+
+	lowest, highest := Assign(10, workers, "d")
+	require.Equal(t, lowest, highest)
+}
+
+func TestMemoryCoordinatorClaim(t *testing.T) {
+	ctx := context.Background()
+	coordinator := NewMemoryCoordinator(time.Millisecond * 50)
+
+	require.NoError(t, coordinator.Join(ctx, "a"))
+	require.NoError(t, coordinator.Join(ctx, "b"))
+
+	require.NoError(t, coordinator.Claim(ctx, "a", 0))
+	require.ErrorIs(t, coordinator.Claim(ctx, "b", 0), ErrClaimed)
+
+	// Releasing a shard that is owned by another worker does nothing
+	require.NoError(t, coordinator.Release(ctx, "b", 0))
+	require.ErrorIs(t, coordinator.Claim(ctx, "b", 0), ErrClaimed)
+
+	require.NoError(t, coordinator.Release(ctx, "a", 0))
+	require.NoError(t, coordinator.Claim(ctx, "b", 0))
+
+	// Once b has expired, its shards can be claimed
+	time.Sleep(time.Millisecond * 60)
+	require.NoError(t, coordinator.Join(ctx, "a"))
+
+	workers, err := coordinator.Workers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, workers)
+	require.NoError(t, coordinator.Claim(ctx, "a", 0))
+}
+
+// hashTag returns the part of key that Redis Cluster hashes to choose its slot
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start != -1 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+
+	return key
+}
+
+func TestRedisCoordinatorKeysShareSlot(t *testing.T) {
+	coordinator := NewRedisCoordinator(nil, "gdl", time.Second)
+
+	// claimScript is passed both keys, which fails with CROSSSLOT on Redis Cluster unless they are in the same slot
+	require.Equal(t, hashTag(coordinator.workersKey()), hashTag(coordinator.claimKey(0)))
+	require.Equal(t, hashTag(coordinator.claimKey(0)), hashTag(coordinator.claimKey(1)))
+}
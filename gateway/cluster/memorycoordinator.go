@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryCoordinator coordinates workers within a single process, which is useful for tests
+type MemoryCoordinator struct {
+	sync.Mutex
+	ttl     time.Duration
+	workers map[string]time.Time // last time each worker joined
+	claims  map[int]string
+}
+
+func NewMemoryCoordinator(ttl time.Duration) *MemoryCoordinator {
+	return &MemoryCoordinator{
+		ttl:     ttl,
+		workers: make(map[string]time.Time),
+		claims:  make(map[int]string),
+	}
+}
+
+func (c *MemoryCoordinator) Join(ctx context.Context, workerId string) error {
+	c.Lock()
+	c.workers[workerId] = time.Now()
+	c.Unlock()
+	return nil
+}
+
+func (c *MemoryCoordinator) Leave(ctx context.Context, workerId string) error {
+	c.Lock()
+	delete(c.workers, workerId)
+	c.Unlock()
+	return nil
+}
+
+func (c *MemoryCoordinator) Workers(ctx context.Context) ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	workers := make([]string, 0, len(c.workers))
+	for workerId := range c.workers {
+		if c.alive(workerId) {
+			workers = append(workers, workerId)
+		}
+	}
+
+	sort.Strings(workers)
+	return workers, nil
+}
+
+func (c *MemoryCoordinator) Claim(ctx context.Context, workerId string, shardId int) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if owner, ok := c.claims[shardId]; ok && owner != workerId && c.alive(owner) {
+		return ErrClaimed
+	}
+
+	c.claims[shardId] = workerId
+	return nil
+}
+
+func (c *MemoryCoordinator) Release(ctx context.Context, workerId string, shardId int) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.claims[shardId] == workerId {
+		delete(c.claims, shardId)
+	}
+
+	return nil
+}
+
+// alive must be called with the lock held
+func (c *MemoryCoordinator) alive(workerId string) bool {
+	joined, ok := c.workers[workerId]
+	return ok && time.Since(joined) < c.ttl
+}
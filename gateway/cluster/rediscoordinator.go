@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RedisCoordinator coordinates workers in different processes. Workers are kept in a sorted set, scored by the last
+// time they joined, so the clocks of the workers should be roughly in sync.
+type RedisCoordinator struct {
+	*redis.Client
+	keyPrefix string
+	ttl       time.Duration
+}
+
+func NewRedisCoordinator(client *redis.Client, keyPrefix string, ttl time.Duration) *RedisCoordinator {
+	return &RedisCoordinator{
+		Client:    client,
+		keyPrefix: keyPrefix,
+		ttl:       ttl,
+	}
+}
+
+// The keys share the {cluster} hash tag, so that they are in the same hash slot on Redis Cluster, as claimScript uses
+// both the claim and workers keys. keyPrefix must not contain a hash tag of its own.
+func (c *RedisCoordinator) workersKey() string {
+	return fmt.Sprintf("%s:{cluster}:workers", c.keyPrefix)
+}
+
+func (c *RedisCoordinator) claimKey(shardId int) string {
+	return fmt.Sprintf("%s:{cluster}:claim:%d", c.keyPrefix, shardId)
+}
+
+// expiredBefore returns the score below which workers are dead
+func (c *RedisCoordinator) expiredBefore() int64 {
+	return time.Now().Add(-c.ttl).UnixMilli()
+}
+
+func (c *RedisCoordinator) Join(ctx context.Context, workerId string) error {
+	return c.ZAdd(ctx, c.workersKey(), &redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: workerId,
+	}).Err()
+}
+
+func (c *RedisCoordinator) Leave(ctx context.Context, workerId string) error {
+	return c.ZRem(ctx, c.workersKey(), workerId).Err()
+}
+
+func (c *RedisCoordinator) Workers(ctx context.Context) ([]string, error) {
+	min := strconv.FormatInt(c.expiredBefore(), 10)
+
+	// Remove dead workers so that the set does not grow forever
+	if err := c.ZRemRangeByScore(ctx, c.workersKey(), "-inf", "("+min).Err(); err != nil {
+		return nil, err
+	}
+
+	// ZRANGEBYSCORE orders workers by the time they last joined, but Workers returns them in ascending order of ID
+	workers, err := c.ZRangeByScore(ctx, c.workersKey(), &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(workers)
+	return workers, nil
+}
+
+// claimScript sets the owner of a shard, unless it is owned by another worker that is still alive.
+// KEYS[1] = claim key, KEYS[2] = workers key, ARGV[1] = worker ID, ARGV[2] = score below which workers are dead
+var claimScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner and owner ~= ARGV[1] then
+	local score = redis.call('ZSCORE', KEYS[2], owner)
+	if score and tonumber(score) >= tonumber(ARGV[2]) then
+		return 0
+	end
+end
+
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+func (c *RedisCoordinator) Claim(ctx context.Context, workerId string, shardId int) error {
+	claimed, err := claimScript.Run(ctx, c.Client, []string{c.claimKey(shardId), c.workersKey()}, workerId, c.expiredBefore()).Int()
+	if err != nil {
+		return err
+	}
+
+	if claimed == 0 {
+		return ErrClaimed
+	}
+
+	return nil
+}
+
+// releaseScript deletes the claim on a shard if it is owned by the worker. KEYS[1] = claim key, ARGV[1] = worker ID
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+
+return 0
+`)
+
+func (c *RedisCoordinator) Release(ctx context.Context, workerId string, shardId int) error {
+	return releaseScript.Run(ctx, c.Client, []string{c.claimKey(shardId)}, workerId).Err()
+}
@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/cache"
+	"github.com/rxdn/gdl/gateway/cluster"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/session"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestClusterHandOff(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+
+	coordinator := cluster.NewMemoryCoordinator(time.Millisecond * 500)
+	sessions := session.NewMemoryStore()
+	rateLimitStore := ratelimit.NewMemoryStore()
+
+	newWorker := func(workerId string) *ShardManager {
+		return NewShardManager(testToken, ShardOptions{
+			ShardCount:           ShardCount{Total: 2},
+			CacheFactory:         cache.MemoryCacheFactory(cache.CacheOptions{}),
+			RateLimitStore:       rateLimitStore,
+			SessionStore:         sessions,
+			LargeShardingBuckets: 2, // so that both shards can identify at once
+			GatewayUrl:           server.URL,
+			Cluster: &ClusterOptions{
+				Coordinator:       coordinator,
+				WorkerId:          workerId,
+				HeartbeatInterval: time.Millisecond * 50,
+			},
+		})
+	}
+
+	shardIds := func(sm *ShardManager) []int {
+		var ids []int
+		for _, shard := range sm.ShardList() {
+			ids = append(ids, shard.ShardId)
+		}
+
+		sort.Ints(ids)
+		return ids
+	}
+
+	a := newWorker("a")
+	t.Cleanup(func() {
+		require.NoError(t, a.Shutdown(context.Background(), false))
+	})
+
+	// A single worker runs every shard
+	a.Connect()
+	waitForReady(t, a)
+
+	require.Equal(t, []int{0, 1}, shardIds(a))
+	require.Equal(t, 2, server.Identifies())
+
+	// Shard 1 is handed over to the second worker, which resumes its session
+	b := newWorker("b")
+	b.Connect()
+
+	require.Eventually(t, func() bool {
+		return server.Resumes() == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	require.Equal(t, []int{0}, shardIds(a))
+	require.Equal(t, []int{1}, shardIds(b))
+	require.Equal(t, 2, server.Identifies())
+
+	// When the second worker leaves, shard 1 is handed back and resumed again
+	require.NoError(t, b.Shutdown(context.Background(), true))
+
+	require.Eventually(t, func() bool {
+		return server.Resumes() == 2 && len(shardIds(a)) == 2
+	}, time.Second*5, time.Millisecond*10)
+
+	require.Equal(t, 2, server.Identifies())
+}
+
+func TestClusterClaimLost(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+
+	coordinator := cluster.NewMemoryCoordinator(time.Millisecond * 500)
+
+	sm := NewShardManager(testToken, ShardOptions{
+		ShardCount:           ShardCount{Total: 4},
+		CacheFactory:         cache.MemoryCacheFactory(cache.CacheOptions{}),
+		RateLimitStore:       ratelimit.NewMemoryStore(),
+		LargeShardingBuckets: 4,
+		GatewayUrl:           server.URL,
+		Cluster: &ClusterOptions{
+			Coordinator:       coordinator,
+			WorkerId:          "a",
+			HeartbeatInterval: time.Millisecond * 50,
+		},
+	})
+
+	t.Cleanup(func() {
+		require.NoError(t, sm.Shutdown(context.Background(), false))
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+	require.Len(t, sm.ShardList(), 4)
+
+	// Another worker claims shard 1, as if this worker had missed enough heartbeats to be considered dead. Shard 1 is
+	// still within this worker's range once the other worker has joined, so it is only stopped because its claim is lost.
+	ctx := context.Background()
+	require.Eventually(t, func() bool {
+		require.NoError(t, coordinator.Leave(ctx, "a"))
+		require.NoError(t, coordinator.Join(ctx, "b"))
+		return coordinator.Claim(ctx, "b", 1) == nil
+	}, time.Second*5, time.Millisecond)
+
+	hasShard := func(shardId int) bool {
+		for _, shard := range sm.ShardList() {
+			if shard.ShardId == shardId {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	require.Eventually(t, func() bool {
+		return !hasShard(1)
+	}, time.Second*5, time.Millisecond*10)
+
+	// Once the other worker has expired, shard 1 is claimed and started again
+	require.Eventually(t, func() bool {
+		return hasShard(1) && len(sm.ShardList()) == 4
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestClusterReadyWaitsForAssignedShards(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+
+	coordinator := cluster.NewMemoryCoordinator(time.Second * 30)
+
+	// Another worker owns shards 2 and 3, and still holds the claim on shard 1, which is assigned to this worker
+	ctx := context.Background()
+	require.NoError(t, coordinator.Join(ctx, "b"))
+	require.NoError(t, coordinator.Claim(ctx, "b", 1))
+
+	sm := NewShardManager(testToken, ShardOptions{
+		ShardCount:           ShardCount{Total: 4},
+		CacheFactory:         cache.MemoryCacheFactory(cache.CacheOptions{}),
+		RateLimitStore:       ratelimit.NewMemoryStore(),
+		LargeShardingBuckets: 4,
+		GatewayUrl:           server.URL,
+		Cluster: &ClusterOptions{
+			Coordinator:       coordinator,
+			WorkerId:          "a",
+			HeartbeatInterval: time.Millisecond * 50,
+		},
+	})
+
+	t.Cleanup(func() {
+		require.NoError(t, sm.Shutdown(context.Background(), false))
+	})
+
+	sm.Connect()
+
+	require.Eventually(t, func() bool {
+		shards := sm.ShardList()
+		return len(shards) == 1 && shards[0].Ready()
+	}, time.Second*5, time.Millisecond*10)
+
+	// Shard 0 is ready, but shard 1 has not been handed over yet
+	waitCtx, cancel := context.WithTimeout(ctx, time.Millisecond*200)
+	defer cancel()
+	require.ErrorIs(t, sm.WaitForReady(waitCtx), context.DeadlineExceeded)
+
+	require.NoError(t, coordinator.Release(ctx, "b", 1))
+	waitForReady(t, sm)
+	require.Len(t, sm.ShardList(), 2)
+}
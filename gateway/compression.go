@@ -0,0 +1,207 @@
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+// Compression is a transport compression scheme, selected with ShardOptions.Compression
+type Compression interface {
+	// Name returns the value of the compress query parameter, or an empty string if messages are not compressed
+	Name() string
+
+	// NewDecompressor is called for every new connection, as the compression context is per connection
+	NewDecompressor() (Decompressor, error)
+}
+
+// Decompressor decompresses the messages received on a single connection, in the order they were received
+type Decompressor interface {
+	// Decompress returns the decompressed message. The returned slice is reused, and is only valid until the next call.
+	Decompress(message []byte) ([]byte, error)
+	Close() error
+}
+
+var (
+	ZlibStream    Compression = zlibStream{}
+	ZstdStream    Compression = zstdStream{}
+	NoCompression Compression = noCompression{}
+)
+
+// outputBuffer is grown to fit the largest message received, and then reused for every message
+type outputBuffer struct {
+	b []byte
+}
+
+// ensure grows the buffer so that at least n bytes are available after the current length
+func (o *outputBuffer) ensure(n int) {
+	if cap(o.b)-len(o.b) < n {
+		grown := make([]byte, len(o.b), 2*cap(o.b)+n)
+		copy(grown, o.b)
+		o.b = grown
+	}
+}
+
+type noCompression struct{}
+
+func (noCompression) Name() string {
+	return ""
+}
+
+func (noCompression) NewDecompressor() (Decompressor, error) {
+	return noDecompressor{}, nil
+}
+
+type noDecompressor struct{}
+
+func (noDecompressor) Decompress(message []byte) ([]byte, error) {
+	return message, nil
+}
+
+func (noDecompressor) Close() error {
+	return nil
+}
+
+// zlibStream uses a single zlib stream for the lifetime of the connection, with every message ending in a
+// Z_SYNC_FLUSH. The DEFLATE reader cannot continue reading a stream after running out of input, so it
+// is reset for every message with the last 32KB of output, which is the only state carried between blocks.
+type zlibStream struct{}
+
+const (
+	zlibWindowSize = 32 * 1024
+	zlibHeaderSize = 2
+)
+
+var zlibSuffix = []byte{0x00, 0x00, 0xff, 0xff}
+
+func (zlibStream) Name() string {
+	return "zlib-stream"
+}
+
+func (zlibStream) NewDecompressor() (Decompressor, error) {
+	return &zlibDecompressor{
+		reader: flate.NewReader(bytes.NewReader(nil)),
+		window: make([]byte, 0, zlibWindowSize),
+	}, nil
+}
+
+type zlibDecompressor struct {
+	reader     io.ReadCloser
+	source     bytes.Reader
+	window     []byte // the last zlibWindowSize bytes of output, used as the dictionary for the next message
+	output     outputBuffer
+	readHeader bool
+}
+
+func (z *zlibDecompressor) Decompress(message []byte) ([]byte, error) {
+	if !bytes.HasSuffix(message, zlibSuffix) {
+		return nil, errors.New("zlib-stream message does not end with Z_SYNC_FLUSH")
+	}
+
+	if !z.readHeader {
+		if len(message) < zlibHeaderSize || message[0]&0x0f != 8 {
+			return nil, errors.New("invalid zlib header")
+		}
+
+		message = message[zlibHeaderSize:]
+		z.readHeader = true
+	}
+
+	z.source.Reset(message)
+	if err := z.reader.(flate.Resetter).Reset(&z.source, z.window); err != nil {
+		return nil, err
+	}
+
+	z.output.b = z.output.b[:0]
+	for {
+		z.output.ensure(len(message) * 4)
+
+		n, err := z.reader.Read(z.output.b[len(z.output.b):cap(z.output.b)])
+		z.output.b = z.output.b[:len(z.output.b)+n]
+
+		// The stream does not end, so running out of input after the sync flush is expected
+		if err == io.ErrUnexpectedEOF && z.source.Len() == 0 {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error whilst inflating: %w", err)
+		}
+	}
+
+	z.updateWindow(z.output.b)
+	return z.output.b, nil
+}
+
+func (z *zlibDecompressor) updateWindow(output []byte) {
+	if len(output) >= zlibWindowSize {
+		z.window = append(z.window[:0], output[len(output)-zlibWindowSize:]...)
+		return
+	}
+
+	if overflow := len(z.window) + len(output) - zlibWindowSize; overflow > 0 {
+		copy(z.window, z.window[overflow:])
+		z.window = z.window[:len(z.window)-overflow]
+	}
+
+	z.window = append(z.window, output...)
+}
+
+func (z *zlibDecompressor) Close() error {
+	return z.reader.Close()
+}
+
+// zstdStream uses a single zstd frame for the lifetime of the connection, with every message ending in a flush
+type zstdStream struct{}
+
+// zstdMaxBlockSize is the largest that a block can be once decompressed
+const zstdMaxBlockSize = 128 * 1024
+
+func (zstdStream) Name() string {
+	return "zstd-stream"
+}
+
+func (zstdStream) NewDecompressor() (Decompressor, error) {
+	d := &zstdDecompressor{}
+
+	// With a concurrency of 1, the decoder decodes synchronously, and each Read returns at most one block, without
+	// reading ahead. This lets us stop reading once the message has been consumed, without the decoder blocking.
+	reader, err := zstd.NewReader(&d.source, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	d.reader = reader
+	return d, nil
+}
+
+type zstdDecompressor struct {
+	reader *zstd.Decoder
+	source bytes.Reader
+	output outputBuffer
+}
+
+func (z *zstdDecompressor) Decompress(message []byte) ([]byte, error) {
+	z.source.Reset(message)
+
+	z.output.b = z.output.b[:0]
+	for z.source.Len() > 0 {
+		// Leave room for an entire block, so that the decoder never holds on to output between messages
+		z.output.ensure(zstdMaxBlockSize + 1)
+
+		n, err := z.reader.Read(z.output.b[len(z.output.b):cap(z.output.b)])
+		z.output.b = z.output.b[:len(z.output.b)+n]
+
+		if err != nil {
+			return nil, fmt.Errorf("error whilst decompressing zstd: %w", err)
+		}
+	}
+
+	return z.output.b, nil
+}
+
+func (z *zstdDecompressor) Close() error {
+	z.reader.Close()
+	return nil
+}
@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testMessages returns gateway-like payloads that repeat earlier messages, so that they refer back to data from earlier
+// frames, along with messages that are larger than the zlib window and the zstd block size
+func testMessages() [][]byte {
+	random := rand.New(rand.NewSource(1))
+
+	var messages [][]byte
+	for i := 0; i < 20; i++ {
+		messages = append(messages, []byte(fmt.Sprintf(`{"op":0,"t":"MESSAGE_CREATE","s":%d,"d":{"id":"%d","content":"hello"}}`, i, i)))
+	}
+
+	// Random content does not compress, so the compressed message is split over several blocks
+	large := make([]byte, 300*1024)
+	for i := range large {
+		large[i] = byte('a' + random.Intn(26))
+	}
+
+	messages = append(messages, []byte(fmt.Sprintf(`{"op":0,"t":"GUILD_CREATE","d":{"name":"%s"}}`, large)))
+	messages = append(messages, []byte(strings.Repeat(`{"op":11}`, 10000)))
+	messages = append(messages, messages[0], messages[20])
+
+	return messages
+}
+
+func decompressAll(t *testing.T, compression Compression, frames [][]byte, messages [][]byte) {
+	decompressor, err := compression.NewDecompressor()
+	require.NoError(t, err)
+	defer decompressor.Close()
+
+	for i, frame := range frames {
+		decompressed, err := decompressor.Decompress(frame)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(messages[i], decompressed), "message %d was not decompressed correctly", i)
+	}
+}
+
+func TestZlibStream(t *testing.T) {
+	messages := testMessages()
+
+	// Each message is flushed with a Z_SYNC_FLUSH, as Discord does, and the stream is never closed
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+
+	frames := make([][]byte, len(messages))
+	for i, message := range messages {
+		_, err := writer.Write(message)
+		require.NoError(t, err)
+		require.NoError(t, writer.Flush())
+
+		frames[i] = append([]byte(nil), buf.Bytes()...)
+		buf.Reset()
+	}
+
+	decompressAll(t, ZlibStream, frames, messages)
+}
+
+func TestZlibStreamIncompleteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+
+	_, err := writer.Write([]byte(`{"op":11}`))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+
+	decompressor, err := ZlibStream.NewDecompressor()
+	require.NoError(t, err)
+	defer decompressor.Close()
+
+	// A message that was split across websocket messages cannot be decompressed until it ends with a Z_SYNC_FLUSH
+	_, err = decompressor.Decompress(buf.Bytes()[:buf.Len()-2])
+	require.Error(t, err)
+}
+
+func TestZstdStream(t *testing.T) {
+	messages := testMessages()
+
+	// Each message is flushed, as Discord does, and the frame is never closed
+	var buf bytes.Buffer
+	encoder, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+
+	frames := make([][]byte, len(messages))
+	for i, message := range messages {
+		_, err := encoder.Write(message)
+		require.NoError(t, err)
+		require.NoError(t, encoder.Flush())
+
+		frames[i] = append([]byte(nil), buf.Bytes()...)
+		buf.Reset()
+	}
+
+	decompressAll(t, ZstdStream, frames, messages)
+}
@@ -0,0 +1,163 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type DispatcherOptions struct {
+	Workers   int // defaults to runtime.NumCPU()
+	QueueSize int // per worker, defaults to 256
+}
+
+type DispatcherStats struct {
+	Queued      int           // events currently waiting to be handled
+	Capacity    int           // total queue capacity across all workers
+	Processed   uint64        // events handled since the dispatcher was started
+	Overflows   uint64        // times the read loop had to wait for space in a full queue
+	BlockedTime time.Duration // total time the read loop spent waiting for space
+}
+
+// dispatcher hands events to a fixed pool of workers. Events are partitioned by guild ID, or by shard ID for events
+// that do not belong to a guild, so that events for the same guild are always handled in the order they were received.
+type dispatcher struct {
+	queues    []chan dispatchJob
+	startOnce sync.Once
+	closeOnce sync.Once
+	workers   goroutineGroup
+
+	processed    atomic.Uint64
+	overflows    atomic.Uint64
+	blockedNanos atomic.Int64
+}
+
+type dispatchJob struct {
+	shard *Shard
+	event *Event
+	scope listenerScope
+}
+
+func newDispatcher(options DispatcherOptions) *dispatcher {
+	if options.Workers <= 0 {
+		options.Workers = runtime.NumCPU()
+	}
+
+	if options.QueueSize <= 0 {
+		options.QueueSize = 256
+	}
+
+	queues := make([]chan dispatchJob, options.Workers)
+	for i := range queues {
+		queues[i] = make(chan dispatchJob, options.QueueSize)
+	}
+
+	return &dispatcher{
+		queues: queues,
+	}
+}
+
+func (d *dispatcher) start() {
+	d.startOnce.Do(func() {
+		for _, queue := range d.queues {
+			d.workers.Go(func() {
+				d.work(queue)
+			})
+		}
+	})
+}
+
+// drain stops accepting events, and waits until every queued event has been handled or ctx is done. It must only be
+// called once nothing else will call enqueue.
+func (d *dispatcher) drain(ctx context.Context) error {
+	d.closeOnce.Do(func() {
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	})
+
+	return d.workers.Wait(ctx)
+}
+
+func (d *dispatcher) work(queue chan dispatchJob) {
+	for job := range queue {
+		d.execute(job)
+		d.processed.Add(1)
+	}
+}
+
+func (d *dispatcher) execute(job dispatchJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			job.shard.ShardManager.ShardOptions.PanicReporter(job.shard, job.event.Type, r, debug.Stack())
+		}
+	}()
+
+	job.shard.executeEvent(job.event, job.scope)
+}
+
+// enqueue blocks if the worker's queue is full, applying backpressure to the shard's read loop
+func (d *dispatcher) enqueue(s *Shard, e *Event, scope listenerScope) {
+	job := dispatchJob{
+		shard: s,
+		event: e,
+		scope: scope,
+	}
+
+	queue := d.queues[partitionKey(job)%uint64(len(d.queues))]
+
+	select {
+	case queue <- job:
+	default:
+		d.overflows.Add(1)
+
+		// Whilst blocked, the shard does not read heartbeat ACKs, so the shard must not treat them as missed
+		s.dispatchBlocked.Store(true)
+		start := time.Now()
+		queue <- job
+		s.dispatchUnblocked.Store(time.Now().UnixNano())
+		s.dispatchBlocked.Store(false)
+
+		d.blockedNanos.Add(int64(time.Since(start)))
+	}
+}
+
+func (d *dispatcher) stats() DispatcherStats {
+	var queued, capacity int
+	for _, queue := range d.queues {
+		queued += len(queue)
+		capacity += cap(queue)
+	}
+
+	return DispatcherStats{
+		Queued:      queued,
+		Capacity:    capacity,
+		Processed:   d.processed.Load(),
+		Overflows:   d.overflows.Load(),
+		BlockedTime: time.Duration(d.blockedNanos.Load()),
+	}
+}
+
+func partitionKey(job dispatchJob) uint64 {
+	if job.event.GuildId != 0 {
+		return job.event.GuildId
+	}
+
+	return uint64(job.shard.ShardId)
+}
+
+// eventGuildId returns the ID of the guild that an event is from, or 0. It is called once per dispatch by the read
+// loop, so it scans for the ID rather than decoding the payload.
+func eventGuildId(eventType events.EventType, data json.RawMessage) uint64 {
+	switch eventType {
+	case events.GUILD_CREATE, events.GUILD_UPDATE, events.GUILD_DELETE:
+		return topLevelSnowflake(data, "id")
+	default:
+		return topLevelSnowflake(data, "guild_id")
+	}
+}
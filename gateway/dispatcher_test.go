@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/rxdn/gdl/cache"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestDispatcher(t *testing.T, workers int) *ShardManager {
+	sm := NewShardManager(testToken, ShardOptions{
+		ShardCount:     ShardCount{Total: 1, Lowest: 0, Highest: 1},
+		CacheFactory:   cache.MemoryCacheFactory(cache.CacheOptions{}),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		Dispatcher:     DispatcherOptions{Workers: workers},
+	})
+
+	sm.dispatcher.start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		require.NoError(t, sm.dispatcher.drain(ctx))
+	})
+
+	return sm
+}
+
+func enqueueMessage(sm *ShardManager, guildId, messageId uint64) {
+	data := json.RawMessage(fmt.Sprintf(`{"id":"%d","guild_id":"%d"}`, messageId, guildId))
+	sm.dispatcher.enqueue(sm.Shards[0], newEvent(events.MESSAGE_CREATE, nil, data), scopeAll)
+}
+
+func TestDispatcherGuildOrdering(t *testing.T) {
+	sm := newTestDispatcher(t, 4)
+
+	var lock sync.Mutex
+	received := make(map[uint64][]uint64)
+
+	var wg sync.WaitGroup
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+
+		lock.Lock()
+		received[e.GuildId] = append(received[e.GuildId], e.Id)
+		lock.Unlock()
+
+		wg.Done()
+	})
+
+	const guilds, messages = 8, 50
+	wg.Add(guilds * messages)
+
+	for messageId := uint64(0); messageId < messages; messageId++ {
+		for guildId := uint64(1); guildId <= guilds; guildId++ {
+			enqueueMessage(sm, guildId, messageId)
+		}
+	}
+
+	wg.Wait()
+
+	for guildId := uint64(1); guildId <= guilds; guildId++ {
+		require.Len(t, received[guildId], messages)
+		for i, messageId := range received[guildId] {
+			require.Equal(t, uint64(i), messageId, "guild %d received messages out of order", guildId)
+		}
+	}
+}
+
+func TestDispatcherGuildsInParallel(t *testing.T) {
+	sm := newTestDispatcher(t, 2)
+
+	// Guilds 2 and 3 are handled by different workers, so guild 2 can wait for guild 3
+	released := make(chan struct{})
+	done := make(chan uint64, 2)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		if e.GuildId == 2 {
+			select {
+			case <-released:
+			case <-time.After(time.Second * 5):
+			}
+		} else {
+			close(released)
+		}
+
+		done <- e.GuildId
+	})
+
+	enqueueMessage(sm, 2, 1)
+	enqueueMessage(sm, 3, 1)
+
+	require.Equal(t, uint64(3), <-done)
+	require.Equal(t, uint64(2), <-done)
+}
+
+func TestEventGuildId(t *testing.T) {
+	// The guild_id of the referenced message must not be mistaken for that of the message
+	data := json.RawMessage(`{"referenced_message": {"guild_id": "1", "content": "a \"quoted\" } string"}, "id": "5", "guild_id" : "2"}`)
+	require.Equal(t, uint64(2), eventGuildId(events.MESSAGE_CREATE, data))
+
+	require.Equal(t, uint64(3), eventGuildId(events.GUILD_CREATE, json.RawMessage(`{"id":"3","channels":[{"id":"4"}]}`)))
+	require.Equal(t, uint64(0), eventGuildId(events.MESSAGE_CREATE, json.RawMessage(`{"id":"5","guild_id":null}`)))
+	require.Equal(t, uint64(0), eventGuildId(events.TYPING_START, json.RawMessage(`{"user_id":"6"}`)))
+}
@@ -3,37 +3,171 @@ package gateway
 import (
 	"encoding/json"
 	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/objects/user"
 	"github.com/sirupsen/logrus"
-	"reflect"
 )
 
-func (s *Shard) ExecuteEvent(eventType events.EventType, data json.RawMessage) {
-	dataType := events.EventTypes[eventType]
-	if dataType == nil {
-		return
+// Event is a dispatch as it passes through the middleware chain to the listeners. The payload is decoded at most once,
+// and the result is shared by every middleware and listener, so listeners must not modify the events they receive.
+type Event struct {
+	Type     events.EventType
+	Sequence *int   // nil if the event was not received from the gateway
+	GuildId  uint64 // the guild that the event is from, or 0
+	Data     json.RawMessage
+
+	decoded       any              // *E, once decoded
+	synthetic     *Event           // the synthetic event derived from this one, once derived
+	syntheticType events.EventType // the synthetic event that a guild event represents, determined as it is read
+}
+
+// listenerScope selects which listeners an event is passed to
+type listenerScope int
+
+const (
+	scopeAll      listenerScope = iota
+	scopeInternal               // only the library's listeners, for shards that are being brought up by Reshard
+	scopeUser                   // only the user's listeners, for events held by Reshard
+)
+
+func newEvent(eventType events.EventType, sequence *int, data json.RawMessage) *Event {
+	return &Event{
+		Type:     eventType,
+		Sequence: sequence,
+		GuildId:  eventGuildId(eventType, data),
+		Data:     data,
+	}
+}
+
+// decodeEvent returns the payload decoded as E, decoding it only the first time it is called for the event
+func decodeEvent[E events.Event](e *Event) *E {
+	if decoded, ok := e.decoded.(*E); ok {
+		return decoded
 	}
 
-	event := reflect.New(dataType)
-	if err := json.Unmarshal(data, event.Interface()); err != nil {
+	var event E
+	if err := json.Unmarshal(e.Data, &event); err != nil {
 		logrus.Warnf("error whilst decoding event data: %s", err.Error())
 	}
-	
-	for _, listener := range s.ShardManager.EventBus.Listeners {
-		fn := reflect.TypeOf(listener)
-		if fn.NumIn() != 2 {
-			continue
-		}
 
-		ptr := fn.In(1)
-		if ptr.Kind() != reflect.Ptr {
-			continue
+	e.decoded = &event
+	return &event
+}
+
+// Author returns the author of a MESSAGE_CREATE or MESSAGE_UPDATE, or nil for any other event
+func (e *Event) Author() *user.User {
+	var author *user.User
+	switch e.Type {
+	case events.MESSAGE_CREATE:
+		author = &decodeEvent[events.MessageCreate](e).Author
+	case events.MESSAGE_UPDATE:
+		author = &decodeEvent[events.MessageUpdate](e).Author
+	}
+
+	// Edits to embeds are sent without the author
+	if author == nil || author.Id == 0 {
+		return nil
+	}
+
+	return author
+}
+
+// ExecuteEvent passes an event to the listeners, as if it had been received from the gateway without a sequence number
+func (s *Shard) ExecuteEvent(eventType events.EventType, data json.RawMessage) {
+	e := newEvent(eventType, nil, data)
+	s.received(e)
+	s.matchWaiters(e)
+	s.executeEvent(e, scopeAll)
+}
+
+// received is called with each event as it is read, before it is dispatched. Guild events are classified here, as
+// whether a GUILD_CREATE is a join depends on the guild events received before it.
+func (s *Shard) received(e *Event) {
+	e.syntheticType, _ = s.guildEventType(e)
+	s.resolveRequests(e)
+}
+
+// matchWaiters passes an event to WaitFor and Collector as it is read, so that a listener waiting for an event does not
+// block the worker that would deliver it. It is only called for events that are passed to the user's listeners.
+func (s *Shard) matchWaiters(e *Event) {
+	executeListeners(s.ShardManager.waiters, s, e)
+}
+
+// resolveRequests passes responses to the requests that are waiting for them. It is called as events are read, rather
+// than by the dispatcher, so that a listener waiting for a response does not block the worker that would deliver it.
+func (s *Shard) resolveRequests(e *Event) {
+	switch e.Type {
+	case events.GUILD_MEMBERS_CHUNK:
+		if s.pendingMemberRequests() {
+			resolveMemberRequest(s, decodeEvent[events.GuildMembersChunk](e))
 		}
+	case events.VOICE_STATE_UPDATE:
+		if s.pendingVoiceRequests() {
+			resolveVoiceState(s, decodeEvent[events.VoiceStateUpdate](e))
+		}
+	case events.VOICE_SERVER_UPDATE:
+		if s.pendingVoiceRequests() {
+			resolveVoiceServer(s, decodeEvent[events.VoiceServerUpdate](e))
+		}
+	}
+}
+
+func (s *Shard) executeEvent(e *Event, scope listenerScope) {
+	if scope != scopeUser {
+		executeListeners(s.ShardManager.internalListeners, s, e)
+	}
+
+	if scope != scopeInternal {
+		s.executeUserListeners(e)
+	}
+}
+
+func (s *Shard) executeUserListeners(e *Event) {
+	handler := s.ShardManager.wrapHandler(func(s *Shard, e *Event) {
+		executeListeners(s.ShardManager.listeners, s, e)
+		s.ShardManager.rawListeners.dispatch(s, e)
+	})
+
+	handler(s, e)
+}
 
-		if ptr.Elem() == dataType {
-			reflect.ValueOf(listener).Call([]reflect.Value{
-				reflect.ValueOf(s),
-				event,
-			})
+func executeListeners(listeners *listenerRegistry, s *Shard, e *Event) {
+	if handlers := listeners.get(e.Type); handlers != nil {
+		handlers.dispatch(s, e)
+	}
+
+	// Synthetic events share the payload of the event they are derived from
+	if e.syntheticType != "" {
+		if handlers := listeners.get(e.syntheticType); handlers != nil {
+			handlers.dispatch(s, e.deriveSynthetic(e.syntheticType))
 		}
 	}
-}
\ No newline at end of file
+}
+
+// deriveSynthetic converts the decoded event to the synthetic type, rather than decoding the payload again
+func (e *Event) deriveSynthetic(syntheticType events.EventType) *Event {
+	if e.synthetic != nil {
+		return e.synthetic
+	}
+
+	var decoded any
+	switch syntheticType {
+	case events.GUILD_JOIN:
+		decoded = &events.GuildJoin{Guild: decodeEvent[events.GuildCreate](e).Guild}
+	case events.GUILD_AVAILABLE:
+		decoded = &events.GuildAvailable{Guild: decodeEvent[events.GuildCreate](e).Guild}
+	case events.GUILD_LEAVE:
+		decoded = &events.GuildLeave{Guild: decodeEvent[events.GuildDelete](e).Guild}
+	case events.GUILD_UNAVAILABLE:
+		decoded = &events.GuildUnavailable{Guild: decodeEvent[events.GuildDelete](e).Guild}
+	}
+
+	e.synthetic = &Event{
+		Type:     syntheticType,
+		Sequence: e.Sequence,
+		GuildId:  e.GuildId,
+		Data:     e.Data,
+		decoded:  decoded,
+	}
+
+	return e.synthetic
+}
@@ -0,0 +1,680 @@
+// Package gatewaytest provides a local websocket server that speaks the Discord gateway protocol, so that shards can
+// be tested without a network connection. Point ShardOptions.GatewayUrl at Server.URL to use it.
+package gatewaytest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/rxdn/gdl/gateway/payloads"
+	"net/http"
+	"net/http/httptest"
+	"nhooyr.io/websocket"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opPresenceUpdate = 3
+	opVoiceState     = 4
+	opResume         = 6
+	opReconnect      = 7
+	opRequestMembers = 8
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// Close codes sent by the server. They match the close codes defined by the gateway package.
+const (
+	CloseUnknownOpcode        websocket.StatusCode = 4001
+	CloseDecodeError          websocket.StatusCode = 4002
+	CloseNotAuthenticated     websocket.StatusCode = 4003
+	CloseAuthenticationFailed websocket.StatusCode = 4004
+	CloseAlreadyAuthenticated websocket.StatusCode = 4005
+	CloseInvalidShard         websocket.StatusCode = 4010
+)
+
+type Options struct {
+	Token             string        // If set, IDENTIFY and RESUME with a different token are closed with 4004
+	HeartbeatInterval time.Duration // Defaults to 41.25 seconds
+	UserId            uint64        // The ID of the bot user sent in READY
+	Guilds            []uint64      // Sent as unavailable in READY to the shard they belong to, each followed by a GUILD_CREATE
+}
+
+// Server is a fake gateway. Dispatches are buffered per session, so that they are replayed when the session is resumed.
+type Server struct {
+	URL       string // ws:// URL of the server
+	ResumeURL string // ws:// URL sent as resume_gateway_url in READY. It serves the same gateway as URL.
+
+	options      Options
+	httpServer   *httptest.Server
+	resumeServer *httptest.Server
+
+	mu          sync.Mutex
+	sessions    map[string]*session
+	connections map[*connection]struct{}
+	ackDisabled bool
+
+	identifies     atomic.Int64
+	resumes        atomic.Int64
+	heartbeats     atomic.Int64
+	resumeRequests atomic.Int64
+}
+
+type session struct {
+	id         string
+	shard      [2]int
+	sequence   int
+	dispatches [][]byte // every dispatch sent in this session, indexed by sequence number - 1
+	connection *connection
+}
+
+// hasGuild returns true if the guild belongs to the session's shard
+func (s *session) hasGuild(guildId uint64) bool {
+	return int((guildId>>22)%uint64(s.shard[1])) == s.shard[0]
+}
+
+type connection struct {
+	server  *Server
+	conn    *websocket.Conn
+	ctx     context.Context
+	session *session // nil until identified or resumed. protected by server.mu
+
+	writeLock  sync.Mutex
+	compressed bool
+	zlibBuffer bytes.Buffer
+	zlibWriter *zlib.Writer
+}
+
+func NewServer(options Options) *Server {
+	if options.HeartbeatInterval == 0 {
+		options.HeartbeatInterval = 41250 * time.Millisecond
+	}
+
+	s := &Server{
+		options:     options,
+		sessions:    make(map[string]*session),
+		connections: make(map[*connection]struct{}),
+	}
+
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = "ws" + strings.TrimPrefix(s.httpServer.URL, "http")
+
+	s.resumeServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.resumeRequests.Add(1)
+		s.handle(w, r)
+	}))
+	s.ResumeURL = "ws" + strings.TrimPrefix(s.resumeServer.URL, "http")
+
+	return s
+}
+
+// Close closes every connection and stops the server
+func (s *Server) Close() {
+	s.CloseConnections(websocket.StatusGoingAway, "server closed")
+	s.httpServer.Close()
+	s.resumeServer.Close()
+}
+
+// CloseResumeURL stops serving ResumeURL, so that connecting to it fails. URL is still served.
+func (s *Server) CloseResumeURL() {
+	s.resumeServer.Close()
+}
+
+// Identifies returns the number of valid IDENTIFY payloads received
+func (s *Server) Identifies() int {
+	return int(s.identifies.Load())
+}
+
+// Identified returns true if a session has identified as shard shardId of shardCount
+func (s *Server) Identified(shardId, shardCount int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.shard == [2]int{shardId, shardCount} {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resumes returns the number of sessions that have been resumed
+func (s *Server) Resumes() int {
+	return int(s.resumes.Load())
+}
+
+// ResumeRequests returns the number of requests made to ResumeURL
+func (s *Server) ResumeRequests() int {
+	return int(s.resumeRequests.Load())
+}
+
+// Heartbeats returns the number of heartbeats received
+func (s *Server) Heartbeats() int {
+	return int(s.heartbeats.Load())
+}
+
+// Connections returns the number of open connections
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.connections)
+}
+
+// SetHeartbeatAck controls whether heartbeats are acknowledged, which can be used to simulate a zombied connection
+func (s *Server) SetHeartbeatAck(enabled bool) {
+	s.mu.Lock()
+	s.ackDisabled = !enabled
+	s.mu.Unlock()
+}
+
+// Dispatch sends an event to every session, or if it has a guild_id, to every session of the shard that the guild
+// belongs to. Sessions that are not connected receive it when they resume.
+func (s *Server) Dispatch(eventName string, data interface{}) error {
+	return s.dispatchWhere(eventName, data, func(*session) bool {
+		return true
+	})
+}
+
+// DispatchShardCount sends an event as Dispatch does, but only to the sessions that identified with shardCount shards,
+// which can be used to simulate an event that one set of shards receives before the other whilst resharding
+func (s *Server) DispatchShardCount(shardCount int, eventName string, data interface{}) error {
+	return s.dispatchWhere(eventName, data, func(session *session) bool {
+		return session.shard[1] == shardCount
+	})
+}
+
+func (s *Server) dispatchWhere(eventName string, data interface{}, filter func(*session) bool) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	var event struct {
+		GuildId uint64 `json:"guild_id,string"`
+	}
+
+	if err := json.Unmarshal(encoded, &event); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		if (event.GuildId == 0 || session.hasGuild(event.GuildId)) && filter(session) {
+			sessions = append(sessions, session)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		if err := s.dispatch(session, eventName, encoded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendRaw writes data to every connection as-is, which can be used to send a malformed payload
+func (s *Server) SendRaw(data []byte) {
+	for _, conn := range s.openConnections() {
+		_ = conn.write(data)
+	}
+}
+
+// RequestHeartbeat asks every connection to heartbeat immediately
+func (s *Server) RequestHeartbeat() {
+	for _, conn := range s.openConnections() {
+		_ = conn.send(opHeartbeat, nil)
+	}
+}
+
+// Reconnect sends a RECONNECT to every connection
+func (s *Server) Reconnect() {
+	for _, conn := range s.openConnections() {
+		_ = conn.send(opReconnect, nil)
+	}
+}
+
+// InvalidateSessions sends an INVALID_SESSION to every connection. If resumable is false, the sessions are deleted.
+func (s *Server) InvalidateSessions(resumable bool) {
+	if !resumable {
+		s.mu.Lock()
+		s.sessions = make(map[string]*session)
+		s.mu.Unlock()
+	}
+
+	for _, conn := range s.openConnections() {
+		_ = conn.send(opInvalidSession, resumable)
+	}
+}
+
+// CloseConnections closes every connection with code. Sessions are kept, so they can be resumed.
+func (s *Server) CloseConnections(code websocket.StatusCode, reason string) {
+	for _, conn := range s.openConnections() {
+		_ = conn.conn.Close(code, reason)
+	}
+}
+
+func (s *Server) openConnections() []*connection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	connections := make([]*connection, 0, len(s.connections))
+	for conn := range s.connections {
+		connections = append(connections, conn)
+	}
+
+	return connections
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if encoding := query.Get("encoding"); encoding != "" && encoding != "json" {
+		http.Error(w, fmt.Sprintf("unsupported encoding %s", encoding), http.StatusBadRequest)
+		return
+	}
+
+	compress := query.Get("compress")
+	if compress != "" && compress != "zlib-stream" {
+		http.Error(w, fmt.Sprintf("unsupported compression %s", compress), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	conn.SetReadLimit(1 << 22)
+
+	c := &connection{
+		server:     s,
+		conn:       conn,
+		ctx:        r.Context(),
+		compressed: compress == "zlib-stream",
+	}
+
+	if c.compressed {
+		c.zlibWriter = zlib.NewWriter(&c.zlibBuffer)
+	}
+
+	s.mu.Lock()
+	s.connections[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.connections, c)
+		if c.session != nil && c.session.connection == c {
+			c.session.connection = nil
+		}
+		s.mu.Unlock()
+
+		_ = conn.Close(websocket.StatusNormalClosure, "")
+	}()
+
+	if err := c.send(opHello, map[string]int64{"heartbeat_interval": s.options.HeartbeatInterval.Milliseconds()}); err != nil {
+		return
+	}
+
+	c.readLoop()
+}
+
+func (c *connection) readLoop() {
+	for {
+		_, data, err := c.conn.Read(c.ctx)
+		if err != nil {
+			return
+		}
+
+		payload, err := payloads.NewPayload(data)
+		if err != nil {
+			_ = c.conn.Close(CloseDecodeError, "decode error")
+			return
+		}
+
+		if err := c.handle(payload); err != nil {
+			return
+		}
+	}
+}
+
+func (c *connection) handle(payload payloads.Payload) error {
+	s := c.server
+
+	switch payload.Opcode {
+	case opHeartbeat:
+		s.heartbeats.Add(1)
+
+		s.mu.Lock()
+		ackDisabled := s.ackDisabled
+		s.mu.Unlock()
+
+		if !ackDisabled {
+			return c.send(opHeartbeatAck, nil)
+		}
+	case opIdentify:
+		return c.identify(payload.Data)
+	case opResume:
+		return c.resume(payload.Data)
+	case opPresenceUpdate:
+		if !c.authenticated() {
+			return c.close(CloseNotAuthenticated, "not authenticated")
+		}
+	case opVoiceState:
+		return c.updateVoiceState(payload.Data)
+	case opRequestMembers:
+		return c.requestMembers(payload.Data)
+	default:
+		return c.close(CloseUnknownOpcode, "unknown opcode "+strconv.Itoa(payload.Opcode))
+	}
+
+	return nil
+}
+
+func (c *connection) authenticated() bool {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	return c.session != nil
+}
+
+// updateVoiceState answers an Update Voice State with the bot's VOICE_STATE_UPDATE, followed by a VOICE_SERVER_UPDATE
+// if it joined a channel
+func (c *connection) updateVoiceState(data json.RawMessage) error {
+	s := c.server
+
+	var update payloads.UpdateVoiceStateData
+	if err := json.Unmarshal(data, &update); err != nil {
+		return c.close(CloseDecodeError, "decode error")
+	}
+
+	s.mu.Lock()
+	session := c.session
+	s.mu.Unlock()
+
+	if session == nil {
+		return c.close(CloseNotAuthenticated, "not authenticated")
+	}
+
+	guildId := strconv.FormatUint(update.GuildId, 10)
+
+	var channelId interface{}
+	if !update.ChannelId.IsNull {
+		channelId = strconv.FormatUint(update.ChannelId.Value, 10)
+	}
+
+	if err := s.dispatchJSON(session, "VOICE_STATE_UPDATE", map[string]interface{}{
+		"guild_id":   guildId,
+		"channel_id": channelId,
+		"user_id":    strconv.FormatUint(s.options.UserId, 10),
+		"session_id": "voice-" + session.id,
+		"self_mute":  update.SelfMute,
+		"self_deaf":  update.SelfDeaf,
+	}); err != nil {
+		return err
+	}
+
+	if channelId == nil {
+		return nil
+	}
+
+	return s.dispatchJSON(session, "VOICE_SERVER_UPDATE", map[string]interface{}{
+		"guild_id": guildId,
+		"token":    "voice-token",
+		"endpoint": "voice.gatewaytest",
+	})
+}
+
+// requestMembers answers a Request Guild Members with a single GUILD_MEMBERS_CHUNK. The server does not track members, so
+// every requested user is reported as not found.
+func (c *connection) requestMembers(data json.RawMessage) error {
+	s := c.server
+
+	var request payloads.RequestGuildMembersData
+	if err := json.Unmarshal(data, &request); err != nil {
+		return c.close(CloseDecodeError, "decode error")
+	}
+
+	s.mu.Lock()
+	session := c.session
+	s.mu.Unlock()
+
+	if session == nil {
+		return c.close(CloseNotAuthenticated, "not authenticated")
+	}
+
+	notFound := make([]string, len(request.UserIds))
+	for i, userId := range request.UserIds {
+		notFound[i] = strconv.FormatUint(userId, 10)
+	}
+
+	return s.dispatchJSON(session, "GUILD_MEMBERS_CHUNK", map[string]interface{}{
+		"guild_id":    strconv.FormatUint(request.GuildId, 10),
+		"members":     []interface{}{},
+		"chunk_index": 0,
+		"chunk_count": 1,
+		"not_found":   notFound,
+		"nonce":       request.Nonce,
+	})
+}
+
+func (c *connection) identify(data json.RawMessage) error {
+	s := c.server
+
+	var identify payloads.IdentifyData
+	if err := json.Unmarshal(data, &identify); err != nil {
+		return c.close(CloseDecodeError, "decode error")
+	}
+
+	if c.authenticated() {
+		return c.close(CloseAlreadyAuthenticated, "already authenticated")
+	}
+
+	if s.options.Token != "" && identify.Token != s.options.Token {
+		return c.close(CloseAuthenticationFailed, "authentication failed")
+	}
+
+	if len(identify.Shard) != 2 || identify.Shard[1] < 1 || identify.Shard[0] < 0 || identify.Shard[0] >= identify.Shard[1] {
+		return c.close(CloseInvalidShard, "invalid shard")
+	}
+
+	session := &session{
+		id:         newSessionId(),
+		shard:      [2]int{identify.Shard[0], identify.Shard[1]},
+		connection: c,
+	}
+
+	s.mu.Lock()
+	s.sessions[session.id] = session
+	c.session = session
+	s.mu.Unlock()
+
+	s.identifies.Add(1)
+
+	var guildIds []uint64
+	for _, guildId := range s.options.Guilds {
+		if session.hasGuild(guildId) {
+			guildIds = append(guildIds, guildId)
+		}
+	}
+
+	guilds := make([]map[string]interface{}, len(guildIds))
+	for i, guildId := range guildIds {
+		guilds[i] = map[string]interface{}{
+			"id":          strconv.FormatUint(guildId, 10),
+			"unavailable": true,
+		}
+	}
+
+	ready := map[string]interface{}{
+		"v":                  9,
+		"user":               map[string]interface{}{"id": strconv.FormatUint(s.options.UserId, 10), "username": "gatewaytest", "bot": true},
+		"guilds":             guilds,
+		"session_id":         session.id,
+		"resume_gateway_url": s.ResumeURL,
+		"shard":              session.shard,
+	}
+
+	if err := s.dispatchJSON(session, "READY", ready); err != nil {
+		return err
+	}
+
+	for _, guildId := range guildIds {
+		guild := map[string]interface{}{
+			"id":   strconv.FormatUint(guildId, 10),
+			"name": fmt.Sprintf("Guild %d", guildId),
+		}
+
+		if err := s.dispatchJSON(session, "GUILD_CREATE", guild); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *connection) resume(data json.RawMessage) error {
+	s := c.server
+
+	var resume payloads.ResumeData
+	if err := json.Unmarshal(data, &resume); err != nil {
+		return c.close(CloseDecodeError, "decode error")
+	}
+
+	if c.authenticated() {
+		return c.close(CloseAlreadyAuthenticated, "already authenticated")
+	}
+
+	if s.options.Token != "" && resume.Token != s.options.Token {
+		return c.close(CloseAuthenticationFailed, "authentication failed")
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[resume.SessionId]
+	if !ok || resume.SequenceNumber > session.sequence || resume.SequenceNumber < 0 {
+		s.mu.Unlock()
+		return c.send(opInvalidSession, false)
+	}
+
+	// Replay the dispatches that were missed, holding the lock so that new dispatches are sent after them
+	session.connection = c
+	c.session = session
+	missed := session.dispatches[resume.SequenceNumber:]
+
+	for _, dispatch := range missed {
+		if err := c.write(dispatch); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+
+	s.resumes.Add(1)
+	return s.dispatchJSON(session, "RESUMED", map[string]interface{}{})
+}
+
+func (s *Server) dispatchJSON(session *session, eventName string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return s.dispatch(session, eventName, encoded)
+}
+
+// dispatch records the event in the session, and sends it if the session is connected
+func (s *Server) dispatch(session *session, eventName string, data json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.sequence++
+
+	encoded, err := json.Marshal(payloads.Payload{
+		Opcode:         opDispatch,
+		Data:           data,
+		SequenceNumber: &session.sequence,
+		EventName:      eventName,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	session.dispatches = append(session.dispatches, encoded)
+
+	if session.connection == nil {
+		return nil
+	}
+
+	// If the write fails, the client will receive the dispatch when it resumes
+	if err := session.connection.write(encoded); err != nil {
+		session.connection = nil
+	}
+
+	return nil
+}
+
+func (c *connection) send(opcode int, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(payloads.Payload{
+		Opcode: opcode,
+		Data:   encoded,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.write(payload)
+}
+
+func (c *connection) write(data []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if !c.compressed {
+		return c.conn.Write(c.ctx, websocket.MessageText, data)
+	}
+
+	c.zlibBuffer.Reset()
+	if _, err := c.zlibWriter.Write(data); err != nil {
+		return err
+	}
+
+	// Flushing ends the message with a Z_SYNC_FLUSH, as Discord does
+	if err := c.zlibWriter.Flush(); err != nil {
+		return err
+	}
+
+	return c.conn.Write(c.ctx, websocket.MessageBinary, c.zlibBuffer.Bytes())
+}
+
+func (c *connection) close(code websocket.StatusCode, reason string) error {
+	_ = c.conn.Close(code, reason)
+	return fmt.Errorf("closed connection with code %d: %s", code, reason)
+}
+
+func newSessionId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+)
+
+// goroutineGroup tracks running goroutines. Unlike sync.WaitGroup, goroutines may be started while another goroutine
+// is waiting, which happens when a shard reconnects during shutdown.
+type goroutineGroup struct {
+	sync.Mutex
+	count int
+	idle  chan struct{} // closed once count reaches 0
+}
+
+func (g *goroutineGroup) Go(fn func()) {
+	g.Lock()
+	if g.count == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.count++
+	g.Unlock()
+
+	go func() {
+		defer g.done()
+		fn()
+	}()
+}
+
+func (g *goroutineGroup) done() {
+	g.Lock()
+	g.count--
+	if g.count == 0 {
+		close(g.idle)
+	}
+	g.Unlock()
+}
+
+// Wait blocks until every goroutine has returned, or ctx is done
+func (g *goroutineGroup) Wait(ctx context.Context) error {
+	for {
+		g.Lock()
+		if g.count == 0 {
+			g.Unlock()
+			return nil
+		}
+		idle := g.idle
+		g.Unlock()
+
+		select {
+		case <-idle:
+			// loop, in case another goroutine was started in the meantime
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
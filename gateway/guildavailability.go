@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/gateway/payloads/events"
+)
+
+// guildEventType returns the synthetic event that a GUILD_CREATE or GUILD_DELETE represents, and updates the set of
+// unavailable guilds. It is called as events are read, so that each event is classified after the events before it.
+func (s *Shard) guildEventType(e *Event) (events.EventType, bool) {
+	if (e.Type != events.GUILD_CREATE && e.Type != events.GUILD_DELETE) || e.GuildId == 0 {
+		return "", false
+	}
+
+	s.readinessLock.Lock()
+	defer s.readinessLock.Unlock()
+
+	if e.Type == events.GUILD_DELETE {
+		if unavailable := decodeEvent[events.GuildDelete](e).Unavailable; unavailable != nil && *unavailable {
+			s.readiness.unavailable[e.GuildId] = struct{}{}
+			return events.GUILD_UNAVAILABLE, true
+		}
+
+		delete(s.readiness.unavailable, e.GuildId)
+		return events.GUILD_LEAVE, true
+	}
+
+	// Guilds from READY are unavailable until their GUILD_CREATE is received, so any other guild is new
+	if _, unavailable := s.readiness.unavailable[e.GuildId]; unavailable {
+		delete(s.readiness.unavailable, e.GuildId)
+		return events.GUILD_AVAILABLE, true
+	}
+
+	return events.GUILD_JOIN, true
+}
@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type guildEvent struct {
+	eventType events.EventType
+	guildId   uint64
+}
+
+func TestGuildEventClassification(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		Guilds: []uint64{1, 2},
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	received := make(chan guildEvent, 16)
+	On(sm, func(s *Shard, e *events.GuildJoin) {
+		received <- guildEvent{events.GUILD_JOIN, e.Id}
+	})
+	On(sm, func(s *Shard, e *events.GuildAvailable) {
+		received <- guildEvent{events.GUILD_AVAILABLE, e.Id}
+	})
+	On(sm, func(s *Shard, e *events.GuildLeave) {
+		received <- guildEvent{events.GUILD_LEAVE, e.Id}
+	})
+	On(sm, func(s *Shard, e *events.GuildUnavailable) {
+		received <- guildEvent{events.GUILD_UNAVAILABLE, e.Id}
+	})
+
+	next := func() guildEvent {
+		select {
+		case e := <-received:
+			return e
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for a guild event")
+			return guildEvent{}
+		}
+	}
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	// The guilds in READY become available as their GUILD_CREATEs are received
+	require.ElementsMatch(t, []guildEvent{
+		{events.GUILD_AVAILABLE, 1},
+		{events.GUILD_AVAILABLE, 2},
+	}, []guildEvent{next(), next()})
+
+	tests := []struct {
+		name      string
+		eventName string
+		data      map[string]interface{}
+		expected  guildEvent
+	}{
+		{
+			name:      "GUILD_CREATE after READY",
+			eventName: "GUILD_CREATE",
+			data:      map[string]interface{}{"id": "3", "name": "Guild 3"},
+			expected:  guildEvent{events.GUILD_JOIN, 3},
+		},
+		{
+			name:      "GUILD_DELETE with unavailable true",
+			eventName: "GUILD_DELETE",
+			data:      map[string]interface{}{"id": "1", "unavailable": true},
+			expected:  guildEvent{events.GUILD_UNAVAILABLE, 1},
+		},
+		{
+			name:      "GUILD_CREATE after an outage",
+			eventName: "GUILD_CREATE",
+			data:      map[string]interface{}{"id": "1", "name": "Guild 1"},
+			expected:  guildEvent{events.GUILD_AVAILABLE, 1},
+		},
+		{
+			name:      "GUILD_DELETE with unavailable false",
+			eventName: "GUILD_DELETE",
+			data:      map[string]interface{}{"id": "2", "unavailable": false},
+			expected:  guildEvent{events.GUILD_LEAVE, 2},
+		},
+		{
+			name:      "GUILD_DELETE without unavailable",
+			eventName: "GUILD_DELETE",
+			data:      map[string]interface{}{"id": "3"},
+			expected:  guildEvent{events.GUILD_LEAVE, 3},
+		},
+	}
+
+	// Each event depends on the guilds left by the previous one, so they are not run as subtests
+	for _, test := range tests {
+		require.NoError(t, server.Dispatch(test.eventName, test.data))
+		require.Equal(t, test.expected, next(), test.name)
+	}
+
+	require.Empty(t, received)
+	require.Empty(t, sm.UnavailableGuilds())
+}
@@ -0,0 +1,135 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"github.com/rxdn/gdl/gateway/payloads"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/objects/member"
+	"github.com/rxdn/gdl/objects/user"
+	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+// GuildMembersResult holds every GUILD_MEMBERS_CHUNK received in response to a RequestGuildMembers call
+type GuildMembersResult struct {
+	GuildId   uint64
+	Nonce     string
+	Members   []member.Member
+	Presences []user.Presence
+	NotFound  []uint64
+}
+
+type memberRequest struct {
+	result GuildMembersResult
+	done   chan struct{}
+}
+
+var memberRequestCounter atomic.Uint64
+
+// ChunkTimeout is the maximum time spent waiting for the members of a large guild when ChunkLargeGuilds is set
+const ChunkTimeout = time.Minute * 5
+
+// RequestGuildMembers sends a Request Guild Members payload, and waits until every chunk has been received or ctx is
+// done. If userIds is not empty, query is ignored. If nonce is empty, a unique nonce is generated. If ctx is done
+// before every chunk has arrived, the members received so far are returned along with the context's error.
+//
+// The chunks are collected as they are read from the gateway, before they reach the dispatcher, so it is safe to call
+// RequestGuildMembers from a listener and wait for the result.
+func (s *Shard) RequestGuildMembers(ctx context.Context, guildId uint64, query string, limit int, presences bool, userIds []uint64, nonce string) (GuildMembersResult, error) {
+	if nonce == "" {
+		nonce = fmt.Sprintf("%d-%d", s.ShardId, memberRequestCounter.Add(1))
+	}
+
+	req := &memberRequest{
+		result: GuildMembersResult{
+			GuildId: guildId,
+			Nonce:   nonce,
+		},
+		done: make(chan struct{}),
+	}
+
+	s.memberRequestsLock.Lock()
+	if _, ok := s.memberRequests[nonce]; ok {
+		s.memberRequestsLock.Unlock()
+		return GuildMembersResult{}, fmt.Errorf("a request with nonce %s is already in progress", nonce)
+	}
+	s.memberRequests[nonce] = req
+	s.memberRequestsLock.Unlock()
+
+	defer func() {
+		s.memberRequestsLock.Lock()
+		delete(s.memberRequests, nonce)
+		s.memberRequestsLock.Unlock()
+	}()
+
+	if err := s.Send(ctx, payloads.NewRequestGuildMembers(guildId, query, limit, presences, userIds, nonce)); err != nil {
+		return GuildMembersResult{}, err
+	}
+
+	select {
+	case <-req.done:
+		return req.result, nil
+	case <-ctx.Done():
+		s.memberRequestsLock.Lock()
+		result := req.result
+		s.memberRequestsLock.Unlock()
+
+		return result, ctx.Err()
+	}
+}
+
+// pendingMemberRequests returns whether any RequestGuildMembers call is waiting for chunks, so that chunks can be
+// passed to the dispatcher without being decoded on the read goroutine when none are
+func (s *Shard) pendingMemberRequests() bool {
+	s.memberRequestsLock.Lock()
+	defer s.memberRequestsLock.Unlock()
+	return len(s.memberRequests) > 0
+}
+
+func resolveMemberRequest(s *Shard, e *events.GuildMembersChunk) {
+	if e.Nonce == "" {
+		return
+	}
+
+	s.memberRequestsLock.Lock()
+	defer s.memberRequestsLock.Unlock()
+
+	req, ok := s.memberRequests[e.Nonce]
+	if !ok {
+		return
+	}
+
+	req.result.Members = append(req.result.Members, e.Members...)
+	req.result.Presences = append(req.result.Presences, e.Presences...)
+	req.result.NotFound = append(req.result.NotFound, e.NotFound...)
+
+	if e.ChunkIndex == e.ChunkCount-1 {
+		close(req.done)
+		delete(s.memberRequests, e.Nonce)
+	}
+}
+
+// chunkLargeGuildListener requests the full member list of large guilds, which are otherwise sent without offline
+// members. The members are stored by the cache listener for GUILD_MEMBERS_CHUNK.
+func chunkLargeGuildListener(s *Shard, e *events.GuildCreate) {
+	if !e.Large {
+		return
+	}
+
+	s.ShardManager.goroutines.Go(func() {
+		ctx, cancel := context.WithTimeout(s.context, ChunkTimeout)
+		defer cancel()
+
+		if _, err := s.RequestGuildMembers(ctx, e.Id, "", 0, false, nil, ""); err != nil {
+			logrus.Warnf("shard %d: Error whilst chunking guild %d: %s", s.ShardId, e.Id, err.Error())
+		}
+	})
+}
+
+func registerMemberRequestListeners(sm *ShardManager) {
+	if sm.ShardOptions.ChunkLargeGuilds {
+		On(sm, chunkLargeGuildListener)
+	}
+}
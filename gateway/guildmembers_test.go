@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRequestGuildMembersFromListener(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		Guilds: []uint64{1},
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	// The chunk is from the same guild as the message, so it would be queued behind this listener if the dispatcher
+	// delivered it
+	results := make(chan error, 1)
+	notFound := make(chan []uint64, 1)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		result, err := s.RequestGuildMembers(ctx, e.GuildId, "", 0, false, []uint64{10, 11}, "")
+		notFound <- result.NotFound
+		results <- err
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{"id": "1", "guild_id": "1"}))
+
+	select {
+	case err := <-results:
+		require.NoError(t, err)
+		require.Equal(t, []uint64{10, 11}, <-notFound)
+	case <-time.After(time.Second * 10):
+		t.Fatal("timed out waiting for RequestGuildMembers to return")
+	}
+
+	require.False(t, sm.Shards[0].pendingMemberRequests())
+}
@@ -2,51 +2,151 @@ package gateway
 
 import (
 	"github.com/rxdn/gdl/gateway/payloads"
-	"github.com/rxdn/gdl/utils"
 	"github.com/sirupsen/logrus"
+	"math/rand"
 	"time"
 )
 
 func (s *Shard) CountdownHeartbeat(ticker *time.Ticker) {
-	loop:
+	s.heartbeatLock.RLock()
+	kill := s.killHeartbeat
+	s.heartbeatLock.RUnlock()
+
+	s.tickHeartbeat(ticker, kill)
+}
+
+// countdownHeartbeat sends the first heartbeat after a random fraction of the interval, as documented by Discord, so
+// that shards which connected at the same time do not heartbeat at the same time, and then once every interval
+func (s *Shard) countdownHeartbeat(interval time.Duration, kill chan struct{}) {
+	timer := time.NewTimer(time.Duration(rand.Float64() * float64(interval)))
+	defer timer.Stop()
+
+	select {
+	case <-kill:
+		return
+	case <-s.context.Done():
+		return
+	case <-timer.C:
+	}
+
+	if !s.beat() {
+		return
+	}
+
+	s.tickHeartbeat(time.NewTicker(interval), kill)
+}
+
+func (s *Shard) tickHeartbeat(ticker *time.Ticker, kill chan struct{}) {
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-s.killHeartbeat:
-			ticker.Stop()
-			break loop
+		case <-kill:
+			return
+		case <-s.context.Done():
+			return
 		case <-ticker.C:
-			s.heartbeatLock.RLock()
-
-			// Check we received an ACK
-			timeElapsed := s.lastHeartbeatAcknowledgement - s.lastHeartbeat
-			if s.hasDoneHeartbeat && timeElapsed > int64(s.heartbeatInterval) {
-				logrus.Warnf("shard %d didn't receive acknowledgement, restarting", s.ShardId)
-				s.heartbeatLock.RUnlock()
-				s.Kill()
-				go s.EnsureConnect()
+			if !s.beat() {
 				return
 			}
+		}
+	}
+}
+
+type sentHeartbeat struct {
+	at        time.Time
+	scheduled bool // false if sent in reply to a request from Discord
+}
 
-			s.heartbeatLock.RUnlock()
+// beat sends a heartbeat, unless the previous scheduled one was never acknowledged, in which case the connection is a
+// zombie and the shard reconnects to resume the session. Returns false if the shard is reconnecting.
+func (s *Shard) beat() bool {
+	if sentAt, ok := s.awaitingScheduledAck(); ok && !s.blockedSince(sentAt) {
+		logrus.Warnf("shard %d: No heartbeat ACK received in %s, reconnecting", s.ShardId, time.Since(sentAt))
+		s.ShardManager.ShardOptions.Metrics.HeartbeatMissed(s.ShardId)
+		_ = s.closeConnection(4000, "heartbeat ACK not received")
+		s.reconnect(0)
+		return false
+	}
 
-			if err := s.Heartbeat(); err != nil {
-				logrus.Warnf("shard %d heartbeat failed, restarting: %s", s.ShardId, err.Error())
-				s.Kill()
-				go s.EnsureConnect()
-			}
-		}
+	if err := s.heartbeat(true); err != nil {
+		logrus.Warnf("shard %d: Error whilst heartbeating, reconnecting: %s", s.ShardId, err.Error())
+		s.Kill()
+		s.reconnect(0)
+		return false
 	}
+
+	return true
 }
 
+// blockedSince returns true if the read loop has been blocked on a full dispatch queue since t. The ACK may then have
+// been received, but be waiting to be read behind the events that are yet to be dispatched.
+func (s *Shard) blockedSince(t time.Time) bool {
+	return s.dispatchBlocked.Load() || s.dispatchUnblocked.Load() > t.UnixNano()
+}
+
+// Heartbeat sends a heartbeat outside of the heartbeat interval. It is not counted when checking for a missed ACK.
 func (s *Shard) Heartbeat() error {
+	return s.heartbeat(false)
+}
+
+func (s *Shard) heartbeat(scheduled bool) error {
 	s.sequenceLock.RLock()
 	payload := payloads.NewHeartbeat(s.sequenceNumber)
 	s.sequenceLock.RUnlock()
 
 	s.heartbeatLock.Lock()
-	s.hasDoneHeartbeat = true
-	s.lastHeartbeat = utils.GetCurrentTimeMillis()
+	s.heartbeatsSent = append(s.heartbeatsSent, sentHeartbeat{
+		at:        time.Now(),
+		scheduled: scheduled,
+	})
 	s.heartbeatLock.Unlock()
 
-	return s.write(payload)
+	// heartbeats may use the allowance reserved for them
+	if err := s.sendLimiter.payloads.wait(s.context, 0); err != nil {
+		return err
+	}
+
+	return s.write(s.context, payload)
+}
+
+// awaitingScheduledAck returns when the oldest scheduled heartbeat that has not been acknowledged was sent, if any.
+// Heartbeats sent because Discord requested one are ignored, so that a request shortly before the interval elapses
+// does not leave too little time for its ACK.
+func (s *Shard) awaitingScheduledAck() (time.Time, bool) {
+	s.heartbeatLock.RLock()
+	defer s.heartbeatLock.RUnlock()
+
+	for _, sent := range s.heartbeatsSent {
+		if sent.scheduled {
+			return sent.at, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// heartbeatAcknowledged is called when a heartbeat ACK is received, and returns the round trip time of the heartbeat.
+// Discord acknowledges heartbeats in the order they are sent, so the ACK is for the oldest outstanding heartbeat.
+func (s *Shard) heartbeatAcknowledged() (time.Duration, bool) {
+	s.heartbeatLock.Lock()
+	defer s.heartbeatLock.Unlock()
+
+	// ACKs may be sent without a heartbeat being outstanding, in which case there is nothing to time
+	if len(s.heartbeatsSent) == 0 {
+		return 0, false
+	}
+
+	sent := s.heartbeatsSent[0]
+	s.heartbeatsSent = s.heartbeatsSent[1:]
+	s.heartbeatRtt = time.Since(sent.at)
+	return s.heartbeatRtt, true
+}
+
+// HeartbeatRtt returns the round trip time of the last acknowledged heartbeat, or 0 if no heartbeat has been
+// acknowledged yet
+func (s *Shard) HeartbeatRtt() time.Duration {
+	s.heartbeatLock.RLock()
+	defer s.heartbeatLock.RUnlock()
+	return s.heartbeatRtt
 }
@@ -19,5 +19,5 @@ func (s *Shard) SelfUsername() string {
 
 // millis
 func (s *Shard) HeartbeatLatency() int64 {
-	return s.lastHeartbeatAcknowledgement - s.lastHeartbeat
+	return s.HeartbeatRtt().Milliseconds()
 }
@@ -2,8 +2,17 @@ package gateway
 
 import "net/http"
 
+// Hooks are called synchronously: ShardReadyHook, ShardResumedHook and AllShardsReadyHook are called from event
+// handlers, and ShardConnectedHook and ShardDisconnectedHook from the shard's connection loop, so they should not block.
 type Hooks struct {
 	ReconnectHook func(*Shard)
 	IdentifyHook  func(*Shard)
 	RestHook      func(token string, req *http.Request)
+	FatalHook     func(*Shard, error) // Called when the shard will not reconnect, e.g. when the token or intents are invalid
+
+	ShardConnectedHook    func(*Shard)                   // Called once the shard has identified or resumed
+	ShardReadyHook        func(*Shard)                   // Called once every guild in READY has been received
+	ShardResumedHook      func(*Shard)                   // Called once a resume has completed
+	ShardDisconnectedHook func(*Shard, CloseCode, error) // Called when the connection is closed, by Discord or by us. The code is 0 if there was no close frame
+	AllShardsReadyHook    func(*ShardManager)            // Called when every shard has become ready
 }
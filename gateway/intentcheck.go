@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/cache"
+	"github.com/rxdn/gdl/gateway/intents"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/sirupsen/logrus"
+)
+
+// requiredIntents maps event types to the intents that Discord sends them for. Receiving an event requires any one of
+// its intents. Events that are not listed are sent regardless of intents.
+var requiredIntents = map[events.EventType][]intents.Intent{
+	events.CHANNEL_CREATE:                {intents.Guilds},
+	events.CHANNEL_UPDATE:                {intents.Guilds},
+	events.CHANNEL_DELETE:                {intents.Guilds},
+	events.CHANNEL_PINS_UPDATE:           {intents.Guilds, intents.DirectMessages},
+	events.GUILD_CREATE:                  {intents.Guilds},
+	events.GUILD_UPDATE:                  {intents.Guilds},
+	events.GUILD_DELETE:                  {intents.Guilds},
+	events.GUILD_JOIN:                    {intents.Guilds},
+	events.GUILD_AVAILABLE:               {intents.Guilds},
+	events.GUILD_LEAVE:                   {intents.Guilds},
+	events.GUILD_UNAVAILABLE:             {intents.Guilds},
+	events.GUILD_BAN_ADD:                 {intents.GuildBans},
+	events.GUILD_BAN_REMOVE:              {intents.GuildBans},
+	events.GUILD_EMOJIS_UPDATE:           {intents.GuildEmojis},
+	events.GUILD_INTEGRATIONS_UPDATE:     {intents.GuildIntegrations},
+	events.GUILD_MEMBER_ADD:              {intents.GuildMembers},
+	events.GUILD_MEMBER_REMOVE:           {intents.GuildMembers},
+	events.GUILD_MEMBER_UPDATE:           {intents.GuildMembers},
+	events.GUILD_ROLE_CREATE:             {intents.Guilds},
+	events.GUILD_ROLE_UPDATE:             {intents.Guilds},
+	events.GUILD_ROLE_DELETE:             {intents.Guilds},
+	events.INVITE_CREATE:                 {intents.GuildInvites},
+	events.INVITE_DELETE:                 {intents.GuildInvites},
+	events.MESSAGE_CREATE:                {intents.GuildMessages, intents.DirectMessages},
+	events.MESSAGE_UPDATE:                {intents.GuildMessages, intents.DirectMessages},
+	events.MESSAGE_DELETE:                {intents.GuildMessages, intents.DirectMessages},
+	events.MESSAGE_DELETE_BULK:           {intents.GuildMessages},
+	events.MESSAGE_REACTION_ADD:          {intents.GuildMessageReactions, intents.DirectMessageReactions},
+	events.MESSAGE_REACTION_REMOVE:       {intents.GuildMessageReactions, intents.DirectMessageReactions},
+	events.MESSAGE_REACTION_REMOVE_ALL:   {intents.GuildMessageReactions, intents.DirectMessageReactions},
+	events.MESSAGE_REACTION_REMOVE_EMOJI: {intents.GuildMessageReactions, intents.DirectMessageReactions},
+	events.PRESENCE_UPDATE:               {intents.GuildPresences},
+	events.THREAD_CREATE:                 {intents.Guilds},
+	events.THREAD_UPDATE:                 {intents.Guilds},
+	events.THREAD_DELETE:                 {intents.Guilds},
+	events.THREAD_LIST_SYNC:              {intents.Guilds},
+	events.THREAD_MEMBER_UPDATE:          {intents.Guilds},
+	events.THREAD_MEMBERS_UPDATE:         {intents.Guilds, intents.GuildMembers},
+	events.TYPING_START:                  {intents.GuildMessageTyping, intents.DirectMessageTyping},
+	events.VOICE_STATE_UPDATE:            {intents.GuildVoiceStates},
+	events.WEBHOOKS_UPDATE:               {intents.GuildWebhooks},
+}
+
+// messageContentEvents are the events for which the content, embeds, attachments and components of guild messages are
+// empty without the MessageContent intent, unless the message mentions the bot
+var messageContentEvents = map[events.EventType]struct{}{
+	events.MESSAGE_CREATE: {},
+	events.MESSAGE_UPDATE: {},
+}
+
+// checkCacheIntents warns about cache options and shard options that can never be satisfied with the configured
+// intents, as the objects would never be received
+func (sm *ShardManager) checkCacheIntents(options cache.CacheOptions) {
+	sum := intents.SumIntents(sm.ShardOptions.Intents...)
+
+	warn := func(enabled bool, option string, intent intents.Intent) {
+		if enabled && !intents.Has(sum, intent) {
+			logrus.Warnf("%s is enabled without the %s intent", option, intent)
+		}
+	}
+
+	warn(options.Guilds, "Guild caching", intents.Guilds)
+	warn(options.Channels, "Channel caching", intents.Guilds)
+	warn(options.Roles, "Role caching", intents.Guilds)
+	warn(options.Emojis, "Emoji caching", intents.GuildEmojis)
+	warn(options.Members, "Member caching", intents.GuildMembers)
+	warn(options.VoiceStates, "Voice state caching", intents.GuildVoiceStates)
+	warn(sm.ShardOptions.ChunkLargeGuilds, "ChunkLargeGuilds", intents.GuildMembers)
+}
+
+// checkListenerIntents warns if a listener is registered for an event that will never be received with the configured
+// intents. Listeners registered by NewShardManager itself are not checked, as they are registered regardless of which
+// events are wanted.
+func (sm *ShardManager) checkListenerIntents(eventType events.EventType) {
+	if !sm.checkIntents {
+		return
+	}
+
+	required, ok := requiredIntents[eventType]
+	if !ok {
+		return
+	}
+
+	sum := intents.SumIntents(sm.ShardOptions.Intents...)
+	if !intents.Has(sum, required...) {
+		logrus.Warnf("Listening for %s events, which require one of the %v intents, none of which are enabled", eventType, required)
+		return
+	}
+
+	// Direct messages always have their content, so only guild messages are affected
+	if _, ok := messageContentEvents[eventType]; ok && intents.Has(sum, intents.GuildMessages) && !intents.Has(sum, intents.MessageContent) {
+		logrus.Warnf("Listening for %s events without the %s intent, so guild messages will have no content unless they mention the bot", eventType, intents.MessageContent)
+	}
+}
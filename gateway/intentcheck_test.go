@@ -0,0 +1,135 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/cache"
+	"github.com/rxdn/gdl/gateway/intents"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// captureWarnings records the warnings logged by the standard logger until the test has finished
+func captureWarnings(t *testing.T) func() []string {
+	previous := logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	hook := test.NewLocal(logrus.StandardLogger())
+	t.Cleanup(func() {
+		logrus.StandardLogger().ReplaceHooks(previous)
+	})
+
+	return func() []string {
+		var warnings []string
+		for _, entry := range hook.AllEntries() {
+			if entry.Level == logrus.WarnLevel {
+				warnings = append(warnings, entry.Message)
+			}
+		}
+
+		hook.Reset()
+		return warnings
+	}
+}
+
+func newIntentsTestShardManager(cacheOptions cache.CacheOptions, enabled ...intents.Intent) *ShardManager {
+	return NewShardManager(testToken, ShardOptions{
+		ShardCount:     ShardCount{Total: 1, Lowest: 0, Highest: 1},
+		CacheFactory:   cache.MemoryCacheFactory(cacheOptions),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		Intents:        enabled,
+	})
+}
+
+func TestCacheIntentWarnings(t *testing.T) {
+	warnings := captureWarnings(t)
+
+	newIntentsTestShardManager(cache.CacheOptions{Guilds: true, Members: true}, intents.Guilds)
+	require.Equal(t, []string{"Member caching is enabled without the GuildMembers intent"}, warnings())
+
+	newIntentsTestShardManager(cache.CacheOptions{Guilds: true, Members: true}, intents.Guilds, intents.GuildMembers)
+	require.Empty(t, warnings())
+}
+
+func TestListenerIntentWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		intents  []intents.Intent
+		listen   func(sm *ShardManager)
+		expected []string
+	}{
+		{
+			name:    "missing intent",
+			intents: []intents.Intent{intents.Guilds},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.TypingStart) {})
+			},
+			expected: []string{"Listening for TYPING_START events, which require one of the [GuildMessageTyping DirectMessageTyping] intents, none of which are enabled"},
+		},
+		{
+			name:    "one of the intents",
+			intents: []intents.Intent{intents.DirectMessageTyping},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.TypingStart) {})
+			},
+		},
+		{
+			name:    "no intent required",
+			intents: []intents.Intent{intents.Guilds},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.Ready) {})
+			},
+		},
+		{
+			name:    "message create without message content",
+			intents: []intents.Intent{intents.GuildMessages},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.MessageCreate) {})
+			},
+			expected: []string{"Listening for MESSAGE_CREATE events without the MessageContent intent, so guild messages will have no content unless they mention the bot"},
+		},
+		{
+			name:    "message update without message content",
+			intents: []intents.Intent{intents.GuildMessages},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.MessageUpdate) {})
+			},
+			expected: []string{"Listening for MESSAGE_UPDATE events without the MessageContent intent, so guild messages will have no content unless they mention the bot"},
+		},
+		{
+			name:    "message create with message content",
+			intents: []intents.Intent{intents.GuildMessages, intents.MessageContent},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.MessageCreate) {})
+			},
+		},
+		{
+			name:    "direct messages without message content",
+			intents: []intents.Intent{intents.DirectMessages},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.MessageCreate) {})
+			},
+		},
+		{
+			name:    "message create without any message intent",
+			intents: []intents.Intent{intents.Guilds, intents.MessageContent},
+			listen: func(sm *ShardManager) {
+				On(sm, func(*Shard, *events.MessageCreate) {})
+			},
+			expected: []string{"Listening for MESSAGE_CREATE events, which require one of the [GuildMessages DirectMessages] intents, none of which are enabled"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings := captureWarnings(t)
+
+			// The cache options are satisfied by every test case, so only the listener is warned about
+			sm := newIntentsTestShardManager(cache.CacheOptions{}, test.intents...)
+			require.Empty(t, warnings())
+
+			test.listen(sm)
+			require.Equal(t, test.expected, warnings())
+		})
+	}
+}
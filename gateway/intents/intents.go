@@ -1,6 +1,8 @@
 package intents
 
-type Intent uint16
+import "fmt"
+
+type Intent uint64
 
 const (
 	Guilds Intent = 1 << iota
@@ -19,17 +21,82 @@ const (
 	DirectMessageReactions
 	DirectMessageTyping
 	MessageContent
+	GuildScheduledEvents
+)
+
+const (
+	AutoModerationConfiguration Intent = 1 << (iota + 20)
+	AutoModerationExecution
+)
+
+const (
+	GuildMessagePolls Intent = 1 << (iota + 24)
+	DirectMessagePolls
+)
+
+// Discord's current names for intents that have been renamed
+const (
+	GuildModeration        = GuildBans
+	GuildEmojisAndStickers = GuildEmojis
 )
 
 var AllIntentsWithoutPrivileged = []Intent{
 	Guilds, GuildBans, GuildEmojis, GuildIntegrations, GuildWebhooks, GuildInvites, GuildVoiceStates, GuildMessages,
 	GuildMessageReactions, GuildMessageTyping, DirectMessages, DirectMessageReactions, DirectMessageTyping,
+	GuildScheduledEvents, AutoModerationConfiguration, AutoModerationExecution, GuildMessagePolls, DirectMessagePolls,
 }
 
-func SumIntents(intents ...Intent) (sum uint16) {
+// Privileged intents must be enabled in the developer portal before they can be used
+var Privileged = []Intent{GuildMembers, GuildPresences, MessageContent}
+
+// SumIntents ORs the intents together, so an intent may be passed more than once
+func SumIntents(intents ...Intent) (sum uint64) {
 	for _, intent := range intents {
-		sum += uint16(intent)
+		sum |= uint64(intent)
 	}
 
 	return
 }
+
+// Has returns whether any of the intents are present in sum
+func Has(sum uint64, intents ...Intent) bool {
+	for _, intent := range intents {
+		if sum&uint64(intent) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+var names = map[Intent]string{
+	Guilds:                      "Guilds",
+	GuildMembers:                "GuildMembers",
+	GuildBans:                   "GuildBans",
+	GuildEmojis:                 "GuildEmojis",
+	GuildIntegrations:           "GuildIntegrations",
+	GuildWebhooks:               "GuildWebhooks",
+	GuildInvites:                "GuildInvites",
+	GuildVoiceStates:            "GuildVoiceStates",
+	GuildPresences:              "GuildPresences",
+	GuildMessages:               "GuildMessages",
+	GuildMessageReactions:       "GuildMessageReactions",
+	GuildMessageTyping:          "GuildMessageTyping",
+	DirectMessages:              "DirectMessages",
+	DirectMessageReactions:      "DirectMessageReactions",
+	DirectMessageTyping:         "DirectMessageTyping",
+	MessageContent:              "MessageContent",
+	GuildScheduledEvents:        "GuildScheduledEvents",
+	AutoModerationConfiguration: "AutoModerationConfiguration",
+	AutoModerationExecution:     "AutoModerationExecution",
+	GuildMessagePolls:           "GuildMessagePolls",
+	DirectMessagePolls:          "DirectMessagePolls",
+}
+
+func (i Intent) String() string {
+	if name, ok := names[i]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Intent(%d)", uint64(i))
+}
@@ -0,0 +1,36 @@
+package intents
+
+import (
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestSumIntents(t *testing.T) {
+	require.Equal(t, uint64(0), SumIntents())
+	require.Equal(t, uint64(1<<0|1<<9|1<<15), SumIntents(Guilds, GuildMessages, MessageContent))
+
+	// Intents are ORed rather than added, so passing one more than once does not set any other bit
+	require.Equal(t, uint64(1<<24|1<<25), SumIntents(GuildMessagePolls, DirectMessagePolls, GuildMessagePolls))
+
+	// Intents above 32 bits are not truncated
+	high := Intent(1 << 40)
+	highest := Intent(1 << 63)
+	require.Equal(t, uint64(1<<40|1<<63|1), SumIntents(high, highest, Guilds, highest))
+	require.Equal(t, uint64(math.MaxUint64), SumIntents(Intent(math.MaxUint64), Guilds))
+}
+
+func TestHas(t *testing.T) {
+	sum := SumIntents(Guilds, DirectMessages, Intent(1<<63))
+
+	require.True(t, Has(sum, Guilds))
+	require.True(t, Has(sum, Intent(1<<63)))
+	require.False(t, Has(sum, GuildMessages))
+	require.False(t, Has(sum, Intent(1<<62)))
+
+	// Any one of the intents is enough
+	require.True(t, Has(sum, GuildMessages, DirectMessages))
+	require.False(t, Has(sum, GuildMessages, MessageContent))
+	require.False(t, Has(sum))
+	require.False(t, Has(0, Guilds))
+}
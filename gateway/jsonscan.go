@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"strconv"
+)
+
+// topLevelString returns the value of a string property of a JSON object without decoding the object, so that it is
+// cheap enough to run on every dispatch. Properties of nested objects are ignored.
+func topLevelString(data []byte, key string) (string, bool) {
+	i := skipWhitespace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return "", false
+	}
+
+	i++
+	for {
+		i = skipWhitespace(data, i)
+		if i >= len(data) || data[i] != '"' {
+			return "", false
+		}
+
+		keyStart := i + 1
+		i = skipString(data, i)
+		if i < 0 {
+			return "", false
+		}
+
+		name := data[keyStart : i-1]
+
+		i = skipWhitespace(data, i)
+		if i >= len(data) || data[i] != ':' {
+			return "", false
+		}
+
+		i = skipWhitespace(data, i+1)
+		if i >= len(data) {
+			return "", false
+		}
+
+		if string(name) == key {
+			if data[i] != '"' {
+				return "", false // e.g. null
+			}
+
+			end := skipString(data, i)
+			if end < 0 {
+				return "", false
+			}
+
+			return string(data[i+1 : end-1]), true
+		}
+
+		i = skipValue(data, i)
+		if i < 0 {
+			return "", false
+		}
+
+		i = skipWhitespace(data, i)
+		if i >= len(data) || data[i] != ',' {
+			return "", false
+		}
+
+		i++
+	}
+}
+
+// topLevelSnowflake returns the value of a snowflake property of a JSON object, or 0 if it is missing or null
+func topLevelSnowflake(data []byte, key string) uint64 {
+	value, ok := topLevelString(data, key)
+	if !ok {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+func skipWhitespace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+
+	return i
+}
+
+// skipString returns the index after the closing quote of the string that starts at i, or -1 if it is not terminated
+func skipString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// skipValue returns the index after the value that starts at i, or -1 if it is not terminated
+func skipValue(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return skipString(data, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				i = skipString(data, i) - 1
+				if i < 0 {
+					return -1
+				}
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+
+		return -1
+	default:
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return i
+			}
+		}
+
+		return i
+	}
+}
@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/gateway/payloads/events"
+)
+
+// registerListener converts a listener of the form func(*Shard, *events.X) into a typed handler, or a raw handler for
+// func(*Shard, *events.RawEvent)
+func registerListener(sm *ShardManager, listener interface{}) (Unsubscribe, bool) {
+	switch fn := listener.(type) {
+	case func(*Shard, *events.Ready):
+		return On(sm, fn), true
+	case func(*Shard, *events.Resumed):
+		return On(sm, fn), true
+	case func(*Shard, *events.Reconnect):
+		return On(sm, fn), true
+	case func(*Shard, *events.InvalidSession):
+		return On(sm, fn), true
+	case func(*Shard, *events.ChannelCreate):
+		return On(sm, fn), true
+	case func(*Shard, *events.ChannelUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.ChannelDelete):
+		return On(sm, fn), true
+	case func(*Shard, *events.ChannelPinsUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.EntitlementCreate):
+		return On(sm, fn), true
+	case func(*Shard, *events.EntitlementUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.EntitlementDelete):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildCreate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildDelete):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildBanAdd):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildBanRemove):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildEmojisUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildIntegrationsUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildMemberAdd):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildMemberRemove):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildMemberUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildMembersChunk):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildRoleCreate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildRoleUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildRoleDelete):
+		return On(sm, fn), true
+	case func(*Shard, *events.InviteCreate):
+		return On(sm, fn), true
+	case func(*Shard, *events.InviteDelete):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageCreate):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageDelete):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageDeleteBulk):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageReactionAdd):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageReactionRemove):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageReactionRemoveAll):
+		return On(sm, fn), true
+	case func(*Shard, *events.MessageReactionRemoveEmoji):
+		return On(sm, fn), true
+	case func(*Shard, *events.PresenceUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.ThreadCreate):
+		return On(sm, fn), true
+	case func(*Shard, *events.ThreadUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.ThreadDelete):
+		return On(sm, fn), true
+	case func(*Shard, *events.ThreadListSync):
+		return On(sm, fn), true
+	case func(*Shard, *events.ThreadMemberUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.ThreadMembersUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.TypingStart):
+		return On(sm, fn), true
+	case func(*Shard, *events.UserUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.VoiceStateUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.VoiceServerUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.WebhooksUpdate):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildJoin):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildAvailable):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildLeave):
+		return On(sm, fn), true
+	case func(*Shard, *events.GuildUnavailable):
+		return On(sm, fn), true
+	case func(*Shard, *events.RawEvent):
+		return OnRaw(sm, fn), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"sync"
+)
+
+// Unsubscribe removes a handler registered with On. It is safe to call more than once.
+type Unsubscribe func()
+
+type listenerRegistry struct {
+	sync.RWMutex
+	handlers map[events.EventType]eventHandlers
+}
+
+// eventHandlers passes the decoded payload for a single event type to every handler
+type eventHandlers interface {
+	dispatch(s *Shard, e *Event)
+}
+
+type handlerSet[E events.Event] struct {
+	sync.RWMutex
+	nextId   uint64
+	handlers []typedHandler[E]
+}
+
+type typedHandler[E events.Event] struct {
+	id uint64
+	fn func(*Shard, *E)
+}
+
+func newListenerRegistry() *listenerRegistry {
+	return &listenerRegistry{
+		handlers: make(map[events.EventType]eventHandlers),
+	}
+}
+
+func (r *listenerRegistry) get(eventType events.EventType) eventHandlers {
+	r.RLock()
+	defer r.RUnlock()
+	return r.handlers[eventType]
+}
+
+// On registers a handler that is called for every event of type E received by any shard
+func On[E events.Event](sm *ShardManager, handler func(*Shard, *E)) Unsubscribe {
+	sm.checkListenerIntents(events.TypeOf[E]())
+	return subscribe(sm.listeners, handler)
+}
+
+func subscribe[E events.Event](registry *listenerRegistry, handler func(*Shard, *E)) Unsubscribe {
+	eventType := events.TypeOf[E]()
+
+	registry.Lock()
+	set, ok := registry.handlers[eventType].(*handlerSet[E])
+	if !ok {
+		set = &handlerSet[E]{}
+		registry.handlers[eventType] = set
+	}
+	registry.Unlock()
+
+	id := set.add(handler)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			set.remove(id)
+		})
+	}
+}
+
+func (h *handlerSet[E]) add(fn func(*Shard, *E)) uint64 {
+	h.Lock()
+	defer h.Unlock()
+
+	id := h.nextId
+	h.nextId++
+
+	// copy on write, so dispatch can iterate without holding the lock
+	handlers := make([]typedHandler[E], len(h.handlers), len(h.handlers)+1)
+	copy(handlers, h.handlers)
+	h.handlers = append(handlers, typedHandler[E]{id: id, fn: fn})
+
+	return id
+}
+
+func (h *handlerSet[E]) remove(id uint64) {
+	h.Lock()
+	defer h.Unlock()
+
+	handlers := make([]typedHandler[E], 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		if handler.id != id {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	h.handlers = handlers
+}
+
+func (h *handlerSet[E]) dispatch(s *Shard, e *Event) {
+	h.RLock()
+	handlers := h.handlers
+	h.RUnlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	event := decodeEvent[E](e)
+	for _, handler := range handlers {
+		handler.fn(s, event)
+	}
+}
@@ -0,0 +1,16 @@
+package gateway
+
+import "github.com/rxdn/gdl/gateway/payloads/events"
+
+func readyMetricsListener(s *Shard, _ *events.Ready) {
+	s.ShardManager.ShardOptions.Metrics.Identified(s.ShardId)
+}
+
+func resumedMetricsListener(s *Shard, _ *events.Resumed) {
+	s.ShardManager.ShardOptions.Metrics.Resumed(s.ShardId)
+}
+
+func registerMetricsListeners(sm *ShardManager) {
+	On(sm, readyMetricsListener)
+	On(sm, resumedMetricsListener)
+}
@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/sirupsen/logrus"
+	"runtime/debug"
+)
+
+// Handler passes an event to the listeners registered with On and OnRaw
+type Handler func(s *Shard, e *Event)
+
+// Middleware wraps the dispatch of every event to the listeners registered with On and OnRaw. It can drop an event by
+// not calling next. The library's own listeners, which keep the cache and the state of the shard up to date, run before
+// the middleware chain, so they still receive events that are dropped.
+type Middleware func(next Handler) Handler
+
+// PanicReporter is called with the value passed to panic and the stack trace. eventType is empty if the panic occurred
+// whilst reading from the gateway, rather than whilst handling an event.
+type PanicReporter func(s *Shard, eventType events.EventType, recovered interface{}, stack []byte)
+
+// LogPanic is the default PanicReporter
+func LogPanic(s *Shard, eventType events.EventType, recovered interface{}, stack []byte) {
+	if eventType == "" {
+		logrus.Warnf("shard %d: Recovered panic while reading: %v\n%s", s.ShardId, recovered, stack)
+	} else {
+		logrus.Warnf("shard %d: Recovered panic while handling %s: %v\n%s", s.ShardId, eventType, recovered, stack)
+	}
+}
+
+// Use appends middleware to the chain. The first middleware registered is the outermost.
+func (sm *ShardManager) Use(middleware ...Middleware) {
+	sm.middlewareLock.Lock()
+	defer sm.middlewareLock.Unlock()
+
+	// copy on write, so ExecuteEvent can build the chain without holding the lock
+	chain := make([]Middleware, len(sm.middleware), len(sm.middleware)+len(middleware))
+	copy(chain, sm.middleware)
+	sm.middleware = append(chain, middleware...)
+}
+
+func (sm *ShardManager) wrapHandler(handler Handler) Handler {
+	sm.middlewareLock.RLock()
+	chain := sm.middleware
+	sm.middlewareLock.RUnlock()
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+
+	return handler
+}
+
+// Recover stops a panic in a listener, or in the middleware after it in the chain, from reaching the dispatcher, and
+// passes it to reporter. If reporter is nil, ShardOptions.PanicReporter is used.
+func Recover(reporter PanicReporter) Middleware {
+	return func(next Handler) Handler {
+		return func(s *Shard, e *Event) {
+			defer func() {
+				if r := recover(); r != nil {
+					if reporter != nil {
+						reporter(s, e.Type, r, debug.Stack())
+					} else {
+						s.ShardManager.ShardOptions.PanicReporter(s, e.Type, r, debug.Stack())
+					}
+				}
+			}()
+
+			next(s, e)
+		}
+	}
+}
+
+// AllowGuilds drops events from any guild that is not listed. Events that are not from a guild are not affected.
+func AllowGuilds(guildIds ...uint64) Middleware {
+	allowed := guildSet(guildIds)
+	return GuildEnabled(func(guildId uint64, _ events.EventType) bool {
+		_, ok := allowed[guildId]
+		return ok
+	})
+}
+
+// DenyGuilds drops events from the listed guilds
+func DenyGuilds(guildIds ...uint64) Middleware {
+	denied := guildSet(guildIds)
+	return GuildEnabled(func(guildId uint64, _ events.EventType) bool {
+		_, ok := denied[guildId]
+		return !ok
+	})
+}
+
+// GuildEnabled drops events from guilds for which enabled returns false, so that listeners can be switched on and off
+// per guild. enabled is called for every event from a guild, so it should not block. Events that are not from a guild
+// are not affected.
+func GuildEnabled(enabled func(guildId uint64, eventType events.EventType) bool) Middleware {
+	return func(next Handler) Handler {
+		return func(s *Shard, e *Event) {
+			if e.GuildId != 0 && !enabled(e.GuildId, e.Type) {
+				return
+			}
+
+			next(s, e)
+		}
+	}
+}
+
+// IgnoreBots drops messages sent by bots, including those sent by the bot itself
+func IgnoreBots() Middleware {
+	return func(next Handler) Handler {
+		return func(s *Shard, e *Event) {
+			if author := e.Author(); author != nil && author.Bot {
+				return
+			}
+
+			next(s, e)
+		}
+	}
+}
+
+func guildSet(guildIds []uint64) map[uint64]struct{} {
+	set := make(map[uint64]struct{}, len(guildIds))
+	for _, guildId := range guildIds {
+		set[guildId] = struct{}{}
+	}
+
+	return set
+}
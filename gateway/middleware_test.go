@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/objects/user"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+	shard := sm.Shards[0]
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(s *Shard, e *Event) {
+				order = append(order, name)
+				next(s, e)
+			}
+		}
+	}
+
+	var reported interface{}
+	sm.Use(trace("outer"), Recover(func(s *Shard, eventType events.EventType, recovered interface{}, stack []byte) {
+		reported = recovered
+	}))
+	sm.Use(trace("inner"), DenyGuilds(2), IgnoreBots())
+
+	var messages []uint64
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		if e.Content == "panic" {
+			panic("listener panicked")
+		}
+
+		messages = append(messages, e.Id)
+	})
+
+	var guilds []uint64
+	On(sm, func(s *Shard, e *events.GuildJoin) {
+		guilds = append(guilds, e.Id)
+	})
+
+	shard.ExecuteEvent(events.MESSAGE_CREATE, json.RawMessage(`{"id":"1","guild_id":"1","author":{"id":"10"}}`))
+	shard.ExecuteEvent(events.MESSAGE_CREATE, json.RawMessage(`{"id":"2","guild_id":"2","author":{"id":"10"}}`))
+	shard.ExecuteEvent(events.MESSAGE_CREATE, json.RawMessage(`{"id":"3","guild_id":"1","author":{"id":"10","bot":true}}`))
+	shard.ExecuteEvent(events.MESSAGE_CREATE, json.RawMessage(`{"id":"4","author":{"id":"10"}}`))
+
+	require.Equal(t, []uint64{1, 4}, messages)
+	require.Equal(t, []string{"outer", "inner", "outer", "inner", "outer", "inner", "outer", "inner"}, order)
+
+	shard.ExecuteEvent(events.MESSAGE_CREATE, json.RawMessage(`{"id":"5","guild_id":"1","content":"panic"}`))
+	require.Equal(t, "listener panicked", reported)
+
+	// Denied guilds are still cached, but the listeners do not receive them
+	shard.ExecuteEvent(events.GUILD_CREATE, json.RawMessage(`{"id":"1","name":"Guild 1"}`))
+	shard.ExecuteEvent(events.GUILD_CREATE, json.RawMessage(`{"id":"2","name":"Guild 2"}`))
+
+	require.Equal(t, []uint64{1}, guilds)
+
+	guild, err := shard.Cache.GetGuild(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, "Guild 2", guild.Name)
+}
+
+func TestEventDecodedOnce(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	var author *user.User
+	sm.Use(func(next Handler) Handler {
+		return func(s *Shard, e *Event) {
+			author = e.Author()
+			next(s, e)
+		}
+	})
+
+	var first, second *events.MessageCreate
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		first = e
+	})
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		second = e
+	})
+
+	sm.Shards[0].ExecuteEvent(events.MESSAGE_CREATE, json.RawMessage(`{"id":"1","author":{"id":"10"}}`))
+
+	// The middleware and every listener share the same decoded payload
+	require.NotNil(t, first)
+	require.Same(t, first, second)
+	require.Same(t, &first.Author, author)
+}
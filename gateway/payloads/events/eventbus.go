@@ -1,12 +1,11 @@
 package events
 
 type EventBus struct {
-	Listeners []interface{}
+	Listeners []interface{} // Record of listeners registered through ShardManager.RegisterListeners; not used for dispatch
 }
 
 func NewEventBus() *EventBus {
-	return &EventBus{
-	}
+	return &EventBus{}
 }
 
 func (e *EventBus) RegisterListener(fn interface{}) {
@@ -50,4 +50,10 @@ const (
 	VOICE_STATE_UPDATE            EventType = "VOICE_STATE_UPDATE"
 	VOICE_SERVER_UPDATE           EventType = "VOICE_SERVER_UPDATE"
 	WEBHOOKS_UPDATE               EventType = "WEBHOOKS_UPDATE"
+
+	// Synthetic events, derived from GUILD_CREATE and GUILD_DELETE
+	GUILD_JOIN        EventType = "GUILD_JOIN"
+	GUILD_AVAILABLE   EventType = "GUILD_AVAILABLE"
+	GUILD_LEAVE       EventType = "GUILD_LEAVE"
+	GUILD_UNAVAILABLE EventType = "GUILD_UNAVAILABLE"
 )
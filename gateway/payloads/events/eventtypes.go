@@ -49,7 +49,11 @@ type Event interface {
 		UserUpdate |
 		VoiceServerUpdate |
 		VoiceStateUpdate |
-		WebhooksUpdate
+		WebhooksUpdate |
+		GuildJoin |
+		GuildAvailable |
+		GuildLeave |
+		GuildUnavailable
 }
 
 var EventTypes = map[EventType]reflect.Type{
@@ -98,6 +102,121 @@ var EventTypes = map[EventType]reflect.Type{
 	TYPING_START:                  reflect.TypeOf(TypingStart{}),
 	USER_UPDATE:                   reflect.TypeOf(UserUpdate{}),
 	VOICE_STATE_UPDATE:            reflect.TypeOf(VoiceStateUpdate{}),
-	VOICE_SERVER_UPDATE:           reflect.TypeOf(VoiceStateUpdate{}),
+	VOICE_SERVER_UPDATE:           reflect.TypeOf(VoiceServerUpdate{}),
 	WEBHOOKS_UPDATE:               reflect.TypeOf(WebhooksUpdate{}),
+	GUILD_JOIN:                    reflect.TypeOf(GuildJoin{}),
+	GUILD_AVAILABLE:               reflect.TypeOf(GuildAvailable{}),
+	GUILD_LEAVE:                   reflect.TypeOf(GuildLeave{}),
+	GUILD_UNAVAILABLE:             reflect.TypeOf(GuildUnavailable{}),
+}
+
+// TypeOf returns the gateway event name that is decoded into E
+func TypeOf[E Event]() EventType {
+	var event E
+	switch any(event).(type) {
+	case Ready:
+		return READY
+	case Resumed:
+		return RESUMED
+	case Reconnect:
+		return RECONNECT
+	case InvalidSession:
+		return INVALID_SESSION
+	case ChannelCreate:
+		return CHANNEL_CREATE
+	case ChannelUpdate:
+		return CHANNEL_UPDATE
+	case ChannelDelete:
+		return CHANNEL_DELETE
+	case ChannelPinsUpdate:
+		return CHANNEL_PINS_UPDATE
+	case EntitlementCreate:
+		return ENTITLEMENT_CREATE
+	case EntitlementUpdate:
+		return ENTITLEMENT_UPDATE
+	case EntitlementDelete:
+		return ENTITLEMENT_DELETE
+	case GuildCreate:
+		return GUILD_CREATE
+	case GuildUpdate:
+		return GUILD_UPDATE
+	case GuildDelete:
+		return GUILD_DELETE
+	case GuildBanAdd:
+		return GUILD_BAN_ADD
+	case GuildBanRemove:
+		return GUILD_BAN_REMOVE
+	case GuildEmojisUpdate:
+		return GUILD_EMOJIS_UPDATE
+	case GuildIntegrationsUpdate:
+		return GUILD_INTEGRATIONS_UPDATE
+	case GuildMemberAdd:
+		return GUILD_MEMBER_ADD
+	case GuildMemberRemove:
+		return GUILD_MEMBER_REMOVE
+	case GuildMemberUpdate:
+		return GUILD_MEMBER_UPDATE
+	case GuildMembersChunk:
+		return GUILD_MEMBERS_CHUNK
+	case GuildRoleCreate:
+		return GUILD_ROLE_CREATE
+	case GuildRoleUpdate:
+		return GUILD_ROLE_UPDATE
+	case GuildRoleDelete:
+		return GUILD_ROLE_DELETE
+	case InviteCreate:
+		return INVITE_CREATE
+	case InviteDelete:
+		return INVITE_DELETE
+	case MessageCreate:
+		return MESSAGE_CREATE
+	case MessageUpdate:
+		return MESSAGE_UPDATE
+	case MessageDelete:
+		return MESSAGE_DELETE
+	case MessageDeleteBulk:
+		return MESSAGE_DELETE_BULK
+	case MessageReactionAdd:
+		return MESSAGE_REACTION_ADD
+	case MessageReactionRemove:
+		return MESSAGE_REACTION_REMOVE
+	case MessageReactionRemoveAll:
+		return MESSAGE_REACTION_REMOVE_ALL
+	case MessageReactionRemoveEmoji:
+		return MESSAGE_REACTION_REMOVE_EMOJI
+	case PresenceUpdate:
+		return PRESENCE_UPDATE
+	case ThreadCreate:
+		return THREAD_CREATE
+	case ThreadUpdate:
+		return THREAD_UPDATE
+	case ThreadDelete:
+		return THREAD_DELETE
+	case ThreadListSync:
+		return THREAD_LIST_SYNC
+	case ThreadMemberUpdate:
+		return THREAD_MEMBER_UPDATE
+	case ThreadMembersUpdate:
+		return THREAD_MEMBERS_UPDATE
+	case TypingStart:
+		return TYPING_START
+	case UserUpdate:
+		return USER_UPDATE
+	case VoiceStateUpdate:
+		return VOICE_STATE_UPDATE
+	case VoiceServerUpdate:
+		return VOICE_SERVER_UPDATE
+	case WebhooksUpdate:
+		return WEBHOOKS_UPDATE
+	case GuildJoin:
+		return GUILD_JOIN
+	case GuildAvailable:
+		return GUILD_AVAILABLE
+	case GuildLeave:
+		return GUILD_LEAVE
+	case GuildUnavailable:
+		return GUILD_UNAVAILABLE
+	default:
+		panic("unreachable")
+	}
 }
@@ -0,0 +1,11 @@
+package events
+
+import (
+	"github.com/rxdn/gdl/objects/guild"
+)
+
+// GuildAvailable is dispatched when a guild that was unavailable in READY, or due to an outage, becomes available.
+// It is derived from GUILD_CREATE, and is not sent by Discord.
+type GuildAvailable struct {
+	guild.Guild
+}
@@ -0,0 +1,10 @@
+package events
+
+import (
+	"github.com/rxdn/gdl/objects/guild"
+)
+
+// GuildJoin is dispatched when we are added to a guild. It is derived from GUILD_CREATE, and is not sent by Discord.
+type GuildJoin struct {
+	guild.Guild
+}
@@ -0,0 +1,10 @@
+package events
+
+import (
+	"github.com/rxdn/gdl/objects/guild"
+)
+
+// GuildLeave is dispatched when we are removed from a guild. It is derived from GUILD_DELETE, and is not sent by Discord.
+type GuildLeave struct {
+	guild.Guild
+}
@@ -7,8 +7,11 @@ import (
 )
 
 type GuildMembersChunk struct {
-	GuildId   uint64                  `json:"guild_id,string"`
-	Members   []member.Member         `json:"member"`
-	NotFound  utils.Uint64StringSlice `json:"not_found"`
-	Presences []user.Presence         `json:"presences"`
+	GuildId    uint64                  `json:"guild_id,string"`
+	Members    []member.Member         `json:"members"`
+	ChunkIndex int                     `json:"chunk_index"`
+	ChunkCount int                     `json:"chunk_count"`
+	NotFound   utils.Uint64StringSlice `json:"not_found"`
+	Presences  []user.Presence         `json:"presences"`
+	Nonce      string                  `json:"nonce"`
 }
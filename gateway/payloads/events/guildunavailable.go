@@ -0,0 +1,11 @@
+package events
+
+import (
+	"github.com/rxdn/gdl/objects/guild"
+)
+
+// GuildUnavailable is dispatched when a guild becomes unavailable due to an outage. It is derived from GUILD_DELETE,
+// and is not sent by Discord.
+type GuildUnavailable struct {
+	guild.Guild
+}
@@ -0,0 +1,11 @@
+package events
+
+import "encoding/json"
+
+// RawEvent is received for every dispatch, including those for event types that the library does not model yet
+type RawEvent struct {
+	Opcode    int
+	EventName string
+	Sequence  *int
+	Data      json.RawMessage
+}
@@ -6,10 +6,11 @@ import (
 )
 
 type Ready struct {
-	GatewayVersion  int           `json:"v"`
-	User            user.User     `json:"user"`
-	PrivateChannels []uint64      `json:"private_channels,string"` // Note: This slice will always be empty
-	Guilds          []guild.Guild `json:"guilds"`
-	SessionId       string        `json:"session_id"`
-	Shard           []int         `json:"shard"` // Slice of [shard_id, num_shards]
+	GatewayVersion   int           `json:"v"`
+	User             user.User     `json:"user"`
+	PrivateChannels  []uint64      `json:"private_channels,string"` // Note: This slice will always be empty
+	Guilds           []guild.Guild `json:"guilds"`
+	SessionId        string        `json:"session_id"`
+	ResumeGatewayUrl string        `json:"resume_gateway_url"`
+	Shard            []int         `json:"shard"` // Slice of [shard_id, num_shards]
 }
@@ -3,5 +3,5 @@ package events
 type VoiceServerUpdate struct {
 	Token    string `json:"token"`
 	GuildId  uint64 `json:"guild_id,string"`
-	Endpoint string `json:"endpoint"`
+	Endpoint string `json:"endpoint"` // empty if the voice server allocated has gone away
 }
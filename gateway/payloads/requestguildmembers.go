@@ -0,0 +1,39 @@
+package payloads
+
+import "github.com/rxdn/gdl/utils"
+
+type (
+	RequestGuildMembers struct {
+		Opcode int                     `json:"op"`
+		Data   RequestGuildMembersData `json:"d"`
+	}
+
+	RequestGuildMembersData struct {
+		GuildId   uint64                  `json:"guild_id,string"`
+		Query     *string                 `json:"query,omitempty"` // Mutually exclusive with UserIds
+		Limit     int                     `json:"limit"`
+		Presences bool                    `json:"presences"`
+		UserIds   utils.Uint64StringSlice `json:"user_ids,omitempty"`
+		Nonce     string                  `json:"nonce,omitempty"` // Max 32 bytes
+	}
+)
+
+func NewRequestGuildMembers(guildId uint64, query string, limit int, presences bool, userIds []uint64, nonce string) RequestGuildMembers {
+	data := RequestGuildMembersData{
+		GuildId:   guildId,
+		Limit:     limit,
+		Presences: presences,
+		Nonce:     nonce,
+	}
+
+	if len(userIds) > 0 {
+		data.UserIds = userIds
+	} else {
+		data.Query = &query
+	}
+
+	return RequestGuildMembers{
+		Opcode: 8,
+		Data:   data,
+	}
+}
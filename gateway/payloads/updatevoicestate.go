@@ -0,0 +1,35 @@
+package payloads
+
+import "github.com/rxdn/gdl/objects"
+
+type (
+	UpdateVoiceState struct {
+		Opcode int                  `json:"op"`
+		Data   UpdateVoiceStateData `json:"d"`
+	}
+
+	UpdateVoiceStateData struct {
+		GuildId   uint64                    `json:"guild_id,string"`
+		ChannelId objects.NullableSnowflake `json:"channel_id"` // null to disconnect
+		SelfMute  bool                      `json:"self_mute"`
+		SelfDeaf  bool                      `json:"self_deaf"`
+	}
+)
+
+// NewUpdateVoiceState builds an Update Voice State payload. A channelId of 0 disconnects from voice.
+func NewUpdateVoiceState(guildId, channelId uint64, selfMute, selfDeaf bool) UpdateVoiceState {
+	channel := objects.NewNullSnowflake()
+	if channelId != 0 {
+		channel = objects.NewNullableSnowflake(channelId)
+	}
+
+	return UpdateVoiceState{
+		Opcode: 4,
+		Data: UpdateVoiceStateData{
+			GuildId:   guildId,
+			ChannelId: channel,
+			SelfMute:  selfMute,
+			SelfDeaf:  selfDeaf,
+		},
+	}
+}
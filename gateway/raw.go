@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"sync"
+)
+
+type rawHandlerSet struct {
+	sync.RWMutex
+	nextId   uint64
+	handlers []rawHandler
+}
+
+type rawHandler struct {
+	id uint64
+	fn func(*Shard, *events.RawEvent)
+}
+
+// OnRaw registers a handler that is called for every dispatch received by any shard, with the payload undecoded. Use
+// it to handle event types that the library does not model yet. Raw handlers run after the handlers registered with
+// On, and are subject to the same middleware.
+func OnRaw(sm *ShardManager, handler func(*Shard, *events.RawEvent)) Unsubscribe {
+	id := sm.rawListeners.add(handler)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			sm.rawListeners.remove(id)
+		})
+	}
+}
+
+func (h *rawHandlerSet) add(fn func(*Shard, *events.RawEvent)) uint64 {
+	h.Lock()
+	defer h.Unlock()
+
+	id := h.nextId
+	h.nextId++
+
+	// copy on write, so dispatch can iterate without holding the lock
+	handlers := make([]rawHandler, len(h.handlers), len(h.handlers)+1)
+	copy(handlers, h.handlers)
+	h.handlers = append(handlers, rawHandler{id: id, fn: fn})
+
+	return id
+}
+
+func (h *rawHandlerSet) remove(id uint64) {
+	h.Lock()
+	defer h.Unlock()
+
+	handlers := make([]rawHandler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		if handler.id != id {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	h.handlers = handlers
+}
+
+func (h *rawHandlerSet) dispatch(s *Shard, e *Event) {
+	h.RLock()
+	handlers := h.handlers
+	h.RUnlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	event := events.RawEvent{
+		Opcode:    0,
+		EventName: string(e.Type),
+		Sequence:  e.Sequence,
+		Data:      e.Data,
+	}
+
+	for _, handler := range handlers {
+		handler.fn(s, &event)
+	}
+}
@@ -0,0 +1,264 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// ReadyTimeout is how long a shard waits for the next initial GUILD_CREATE before considering itself ready anyway.
+// Guilds that are unavailable due to an outage are not sent until they recover, which may take much longer.
+const ReadyTimeout = time.Second * 15
+
+// readiness tracks the guilds sent in READY that we have not yet received a GUILD_CREATE for
+type readiness struct {
+	ready        bool
+	readyHandled bool                // whether the READY listeners have run
+	pending      map[uint64]struct{} // guilds from READY that have not been received yet
+	unavailable  map[uint64]struct{} // guilds that are currently unavailable, including pending guilds
+	timer        *time.Timer
+}
+
+// Ready returns true if the shard has received every guild that was sent as unavailable in READY, or if ReadyTimeout
+// passed without receiving them.
+func (s *Shard) Ready() bool {
+	s.readinessLock.Lock()
+	defer s.readinessLock.Unlock()
+	return s.readiness.ready
+}
+
+// UnavailableGuilds returns the IDs of the guilds on this shard that are currently unavailable
+func (s *Shard) UnavailableGuilds() []uint64 {
+	s.readinessLock.Lock()
+	defer s.readinessLock.Unlock()
+
+	guildIds := make([]uint64, 0, len(s.readiness.unavailable))
+	for guildId := range s.readiness.unavailable {
+		guildIds = append(guildIds, guildId)
+	}
+
+	return guildIds
+}
+
+// UnavailableGuilds returns the IDs of the guilds on every shard that are currently unavailable
+func (sm *ShardManager) UnavailableGuilds() []uint64 {
+	var guildIds []uint64
+	for _, shard := range sm.ShardList() {
+		guildIds = append(guildIds, shard.UnavailableGuilds()...)
+	}
+
+	return guildIds
+}
+
+// WaitForReady blocks until every shard has been ready at the same time, or ctx is done
+func (sm *ShardManager) WaitForReady(ctx context.Context) error {
+	select {
+	case <-sm.allReady:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// beginReady is called by the read loop when READY is received, before the event is dispatched, so that the guilds
+// are known before any GUILD_CREATE listener runs.
+func (s *Shard) beginReady(data json.RawMessage) {
+	var ready struct {
+		User struct {
+			Id uint64 `json:"id,string"`
+		} `json:"user"`
+		Guilds []struct {
+			Id uint64 `json:"id,string"`
+		} `json:"guilds"`
+	}
+
+	if err := json.Unmarshal(data, &ready); err != nil {
+		logrus.Warnf("shard %d: Error whilst decoding guilds from READY: %s", s.ShardId, err.Error())
+	}
+
+	s.selfId.Store(ready.User.Id)
+
+	s.readinessLock.Lock()
+	if s.readiness.timer != nil {
+		s.readiness.timer.Stop()
+	}
+
+	s.readiness = readiness{
+		pending:     make(map[uint64]struct{}, len(ready.Guilds)),
+		unavailable: make(map[uint64]struct{}, len(ready.Guilds)),
+	}
+
+	for _, guild := range ready.Guilds {
+		s.readiness.pending[guild.Id] = struct{}{}
+		s.readiness.unavailable[guild.Id] = struct{}{}
+	}
+
+	s.readiness.timer = time.AfterFunc(ReadyTimeout, s.readyTimedOut)
+	s.readinessLock.Unlock()
+
+	s.ShardManager.readyLock.Lock()
+	s.ShardManager.allShardsReady = false
+	s.ShardManager.readyLock.Unlock()
+}
+
+// checkReady marks the shard as ready if every pending guild has been received. It must be called with readinessLock
+// held, and returns true if the shard has just become ready, in which case the caller must call onReady once the lock
+// has been released.
+func (s *Shard) checkReady() bool {
+	if s.readiness.ready || !s.readiness.readyHandled || len(s.readiness.pending) > 0 {
+		return false
+	}
+
+	s.readiness.ready = true
+	if s.readiness.timer != nil {
+		s.readiness.timer.Stop()
+	}
+
+	return true
+}
+
+func (s *Shard) readyTimedOut() {
+	if s.context.Err() != nil {
+		return
+	}
+
+	s.readinessLock.Lock()
+	if s.readiness.ready {
+		s.readinessLock.Unlock()
+		return
+	}
+
+	logrus.Warnf("shard %d: Timed out waiting for %d guilds, marking shard as ready", s.ShardId, len(s.readiness.pending))
+
+	s.readiness.ready = true
+	s.readiness.pending = make(map[uint64]struct{})
+	s.readinessLock.Unlock()
+
+	s.onReady()
+}
+
+func (s *Shard) onReady() {
+	if s.deferReady() {
+		return
+	}
+
+	logrus.Infof("shard %d: Ready", s.ShardId)
+
+	if s.ShardManager.ShardOptions.Hooks.ShardReadyHook != nil {
+		s.ShardManager.ShardOptions.Hooks.ShardReadyHook(s)
+	}
+
+	s.ShardManager.shardReady()
+}
+
+// shardReady calls AllShardsReadyHook if every shard is now ready
+func (sm *ShardManager) shardReady() {
+	sm.readyLock.Lock()
+	if sm.allShardsReady {
+		sm.readyLock.Unlock()
+		return
+	}
+
+	lowest, highest := sm.readyRange()
+	if lowest >= highest {
+		sm.readyLock.Unlock()
+		return
+	}
+
+	sm.shardsLock.RLock()
+	shards := sm.Shards
+	sm.shardsLock.RUnlock()
+
+	for shardId := lowest; shardId < highest; shardId++ {
+		if shard, ok := shards[shardId]; !ok || !shard.Ready() {
+			sm.readyLock.Unlock()
+			return
+		}
+	}
+
+	sm.allShardsReady = true
+	sm.allReadyOnce.Do(func() {
+		close(sm.allReady)
+	})
+	sm.readyLock.Unlock()
+
+	logrus.Info("All shards ready")
+
+	if sm.ShardOptions.Hooks.AllShardsReadyHook != nil {
+		sm.ShardOptions.Hooks.AllShardsReadyHook(sm)
+	}
+}
+
+// readyRange returns the shards [lowest, highest) that must be ready for every shard to be ready. In cluster mode, these
+// are the shards assigned to this worker, including those that have not yet been handed over by their previous owner.
+func (sm *ShardManager) readyRange() (lowest, highest int) {
+	if sm.cluster != nil {
+		return sm.cluster.assigned()
+	}
+
+	count := sm.shardCount()
+	return count.Lowest, count.Highest
+}
+
+// The readiness listeners are registered after the cache listeners, so the cache has been populated with a guild by
+// the time it is no longer pending
+func readyReadinessListener(s *Shard, _ *events.Ready) {
+	s.readinessLock.Lock()
+	s.readiness.readyHandled = true
+	ready := s.checkReady()
+	s.readinessLock.Unlock()
+
+	if ready {
+		s.onReady()
+	}
+}
+
+func resumedHookListener(s *Shard, _ *events.Resumed) {
+	if s.ShardManager.ShardOptions.Hooks.ShardResumedHook != nil {
+		s.ShardManager.ShardOptions.Hooks.ShardResumedHook(s)
+	}
+}
+
+// The set of unavailable guilds is updated by guildEventType as the events are read
+func guildCreateReadinessListener(s *Shard, e *events.GuildCreate) {
+	s.readinessLock.Lock()
+	if _, ok := s.readiness.pending[e.Id]; ok {
+		delete(s.readiness.pending, e.Id)
+
+		// Give Discord another ReadyTimeout to send the next guild
+		if !s.readiness.ready && s.readiness.timer != nil {
+			s.readiness.timer.Reset(ReadyTimeout)
+		}
+	}
+
+	ready := s.checkReady()
+	s.readinessLock.Unlock()
+
+	if ready {
+		s.onReady()
+	}
+}
+
+func guildDeleteReadinessListener(s *Shard, e *events.GuildDelete) {
+	s.readinessLock.Lock()
+	if e.Unavailable == nil || !*e.Unavailable {
+		// We were removed from the guild, so it will never be sent
+		delete(s.readiness.pending, e.Id)
+	}
+
+	ready := s.checkReady()
+	s.readinessLock.Unlock()
+
+	if ready {
+		s.onReady()
+	}
+}
+
+func registerReadinessListeners(sm *ShardManager) {
+	On(sm, readyReadinessListener)
+	On(sm, resumedHookListener)
+	On(sm, guildCreateReadinessListener)
+	On(sm, guildDeleteReadinessListener)
+}
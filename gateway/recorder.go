@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/rxdn/gdl/gateway/payloads"
+	"io"
+	"sync"
+	"time"
+)
+
+// RecordedPayload is a single line written by a Recorder
+type RecordedPayload struct {
+	Timestamp      time.Time       `json:"timestamp"`
+	ShardId        int             `json:"shard_id"`
+	Opcode         int             `json:"op"`
+	EventName      string          `json:"t,omitempty"`
+	SequenceNumber *int            `json:"s,omitempty"`
+	Data           json.RawMessage `json:"d,omitempty"`
+}
+
+// Recorder writes every payload received by the shards, after decompression and decoding, as newline-delimited JSON.
+// Set ShardOptions.Recorder to record, and use a ReplayShardManager to replay the recording.
+type Recorder struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w: w,
+	}
+}
+
+func (r *Recorder) Record(shardId int, payload payloads.Payload) error {
+	encoded, err := json.Marshal(RecordedPayload{
+		Timestamp:      time.Now(),
+		ShardId:        shardId,
+		Opcode:         payload.Opcode,
+		EventName:      payload.EventName,
+		SequenceNumber: payload.SequenceNumber,
+		Data:           payload.Data,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	encoded = append(encoded, '\n')
+
+	// Write each line with a single call, so that lines from different shards are not interleaved
+	r.Lock()
+	defer r.Unlock()
+
+	_, err = r.w.Write(encoded)
+	return err
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/rxdn/gdl/cache"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		UserId: 5,
+		Guilds: []uint64{1, 2},
+	})
+
+	var recording bytes.Buffer
+	sm := NewShardManager(testToken, ShardOptions{
+		ShardCount:     ShardCount{Total: 1, Lowest: 0, Highest: 1},
+		CacheFactory:   cache.MemoryCacheFactory(cache.CacheOptions{Guilds: true}),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		GatewayUrl:     server.URL,
+		Recorder:       NewRecorder(&recording),
+	})
+
+	messages := make(chan uint64, 1)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		messages <- e.Id
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{"id": "100", "guild_id": "1", "content": "hello"}))
+
+	select {
+	case <-messages:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for MESSAGE_CREATE")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.NoError(t, sm.Shutdown(ctx, false))
+
+	// Replaying the recording passes the same events to the listeners, and populates the cache
+	rsm := NewReplayShardManager(ShardOptions{
+		CacheFactory:   cache.MemoryCacheFactory(cache.CacheOptions{Guilds: true}),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+	})
+	rsm.Speed = 0
+
+	var replayed []events.EventType
+	OnRaw(rsm.ShardManager, func(s *Shard, e *events.RawEvent) {
+		replayed = append(replayed, events.EventType(e.EventName))
+	})
+
+	var content string
+	On(rsm.ShardManager, func(s *Shard, e *events.MessageCreate) {
+		content = e.Content
+	})
+
+	require.NoError(t, rsm.Replay(context.Background(), bytes.NewReader(recording.Bytes())))
+
+	require.Equal(t, []events.EventType{events.READY, events.GUILD_CREATE, events.GUILD_CREATE, events.MESSAGE_CREATE}, replayed)
+	require.Equal(t, "hello", content)
+
+	shard := rsm.Shards[0]
+	require.True(t, shard.Ready())
+	require.Equal(t, uint64(5), shard.SelfId())
+
+	guild, err := shard.Cache.GetGuild(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, "Guild 2", guild.Name)
+}
+
+func TestReplaySkipsUnknownShards(t *testing.T) {
+	var recording bytes.Buffer
+	recorder := NewRecorder(&recording)
+
+	sequence := 1
+	for _, shardId := range []int{0, 1} {
+		require.NoError(t, recorder.Record(shardId, payloads.Payload{
+			Opcode:         0,
+			EventName:      string(events.MESSAGE_CREATE),
+			SequenceNumber: &sequence,
+			Data:           json.RawMessage(`{"id":"1"}`),
+		}))
+	}
+
+	// Every payload is written as a single line
+	lines := strings.Split(strings.TrimSuffix(recording.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var recorded RecordedPayload
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &recorded))
+	require.Equal(t, 1, recorded.ShardId)
+	require.Equal(t, "MESSAGE_CREATE", recorded.EventName)
+
+	rsm := NewReplayShardManager(ShardOptions{
+		CacheFactory:   cache.MemoryCacheFactory(cache.CacheOptions{}),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+	})
+	rsm.Speed = 0
+
+	var shards []int
+	On(rsm.ShardManager, func(s *Shard, e *events.MessageCreate) {
+		shards = append(shards, s.ShardId)
+	})
+
+	require.NoError(t, rsm.Replay(context.Background(), &recording))
+	require.Equal(t, []int{0}, shards)
+}
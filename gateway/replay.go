@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/sirupsen/logrus"
+	"io"
+	"time"
+)
+
+// ReplayShardManager feeds payloads written by a Recorder through the same listeners and cache listeners as a live
+// shard manager, without connecting to Discord. Register listeners on the embedded ShardManager as usual.
+type ReplayShardManager struct {
+	*ShardManager
+	Speed float64 // 1 replays in real time, 2 at twice the speed, and 0 as fast as possible
+}
+
+// NewReplayShardManager creates a shard manager for replaying. ShardCount should match the recording, and defaults to a
+// single shard.
+func NewReplayShardManager(shardOptions ShardOptions) *ReplayShardManager {
+	if shardOptions.ShardCount.Highest == 0 {
+		shardOptions.ShardCount = ShardCount{
+			Total:   1,
+			Lowest:  0,
+			Highest: 1,
+		}
+	}
+
+	return &ReplayShardManager{
+		ShardManager: NewShardManager("", shardOptions),
+		Speed:        1,
+	}
+}
+
+// Replay reads payloads from r until EOF or ctx is done. Events are handled one at a time, in the order they were
+// recorded, so that replays are deterministic. Payloads recorded by shards that this shard manager does not have are
+// skipped.
+func (rsm *ReplayShardManager) Replay(ctx context.Context, r io.Reader) error {
+	decoder := json.NewDecoder(r)
+
+	var previous time.Time
+	for {
+		var recorded RecordedPayload
+		if err := decoder.Decode(&recorded); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !previous.IsZero() && rsm.Speed > 0 {
+			delay := time.Duration(float64(recorded.Timestamp.Sub(previous)) / rsm.Speed)
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+		}
+
+		previous = recorded.Timestamp
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Only dispatches are handled by listeners
+		if recorded.Opcode != 0 {
+			continue
+		}
+
+		shard, ok := rsm.Shards[recorded.ShardId]
+		if !ok {
+			logrus.Warnf("Skipping %s recorded by shard %d, which is not in the replay shard manager", recorded.EventName, recorded.ShardId)
+			continue
+		}
+
+		shard.replay(recorded)
+	}
+}
+
+// replay handles a recorded dispatch in the same way as read, but synchronously
+func (s *Shard) replay(recorded RecordedPayload) {
+	if recorded.SequenceNumber != nil {
+		s.sequenceLock.Lock()
+		s.sequenceNumber = recorded.SequenceNumber
+		s.sequenceLock.Unlock()
+	}
+
+	s.lastEvent.Store(recorded.Timestamp.UnixNano())
+
+	event := events.EventType(recorded.EventName)
+	if event == events.READY {
+		s.beginReady(recorded.Data)
+	}
+
+	e := newEvent(event, recorded.SequenceNumber, recorded.Data)
+	s.received(e)
+	s.matchWaiters(e)
+	s.executeEvent(e, scopeAll)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,387 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/sirupsen/logrus"
+	"hash/fnv"
+	"nhooyr.io/websocket"
+	"sync"
+	"time"
+)
+
+var ErrReshardCluster = errors.New("resharding is not supported in cluster mode")
+
+// dispatchMode controls what happens to the dispatches that a shard receives
+type dispatchMode int
+
+const (
+	dispatchNormal  dispatchMode = iota // dispatches are queued for every listener
+	dispatchHold                        // the shard is being brought up by Reshard, whilst the current shards dispatch
+	dispatchDiscard                     // dispatches are dropped, as the shard has been replaced by Reshard
+)
+
+// dispatch queues a dispatch for the listeners. Whilst a shard is being brought up by Reshard, the current shards are
+// still dispatching, so only the library's listeners are run, to populate the cache and track readiness, and the event
+// is held for the user's listeners until the shard is released. Both sets are sent the same events, though not at the
+// same time, so events are deduplicated between the two sets until reshardDedupeWindow after the new shards are
+// released.
+func (s *Shard) dispatch(e *Event) {
+	s.dispatchLock.Lock()
+	defer s.dispatchLock.Unlock()
+
+	switch s.dispatchMode {
+	case dispatchHold:
+		if held := s.reshard.hold(e); held != nil {
+			s.held = append(s.held, held)
+		}
+
+		s.ShardManager.dispatcher.enqueue(s, e, scopeInternal)
+	case dispatchDiscard:
+	default:
+		if s.reshard != nil && !s.reshard.dispatching(s, e) {
+			s.ShardManager.dispatcher.enqueue(s, e, scopeInternal)
+			return
+		}
+
+		s.matchWaiters(e)
+		s.ShardManager.dispatcher.enqueue(s, e, scopeAll)
+	}
+}
+
+// release passes the held events that were not dispatched by the current shards to the user's listeners, and starts
+// dispatching normally. If the shard became ready whilst it was held, the ready hooks are called now that it is in
+// Shards.
+func (s *Shard) release() {
+	s.dispatchLock.Lock()
+	for _, held := range s.held {
+		if held.dispatched {
+			continue
+		}
+
+		s.matchWaiters(held.Event)
+		s.ShardManager.dispatcher.enqueue(s, held.Event, scopeUser)
+	}
+
+	s.held = nil
+	s.dispatchMode = dispatchNormal
+
+	readyDeferred := s.readyDeferred
+	s.readyDeferred = false
+	s.dispatchLock.Unlock()
+
+	if readyDeferred {
+		s.onReady()
+	}
+}
+
+func (s *Shard) setDispatchMode(mode dispatchMode) {
+	s.dispatchLock.Lock()
+	defer s.dispatchLock.Unlock()
+
+	s.held = nil
+	s.dispatchMode = mode
+}
+
+func (s *Shard) setReshard(reshard *reshardDedupe) {
+	s.dispatchLock.Lock()
+	defer s.dispatchLock.Unlock()
+
+	s.reshard = reshard
+}
+
+// endReshard stops deduplicating events, unless the shard has since been included in another Reshard
+func (s *Shard) endReshard(reshard *reshardDedupe) {
+	s.dispatchLock.Lock()
+	defer s.dispatchLock.Unlock()
+
+	if s.reshard == reshard {
+		s.reshard = nil
+	}
+}
+
+// identifying is called just before the shard identifies. If the shard is being brought up by Reshard, the events
+// dispatched by the current shards are recorded from now on, as the new session may be sent any of them.
+func (s *Shard) identifying() {
+	s.dispatchLock.Lock()
+	reshard := s.reshard
+	s.dispatchLock.Unlock()
+
+	if reshard != nil {
+		reshard.identifying(s)
+	}
+}
+
+// deferReady returns true if the shard is held, in which case onReady is called once the shard is released
+func (s *Shard) deferReady() bool {
+	s.dispatchLock.Lock()
+	defer s.dispatchLock.Unlock()
+
+	if s.dispatchMode != dispatchHold {
+		return false
+	}
+
+	s.readyDeferred = true
+	return true
+}
+
+// Reshard replaces the running shards with a new set of newTotal shards, without downtime. The new shards are
+// connected alongside the current ones, which keep dispatching events to listeners until every new shard is ready.
+// Then, the new shards replace the current ones in ShardForGuild and begin dispatching to listeners, starting with their
+// READY and initial GUILD_CREATEs, followed by the events they received whilst being brought up that the current shards
+// did not dispatch, and the current shards are disconnected. Identifies are subject to the identify rate
+// limit as usual.
+//
+// If only some shards are run by this process, the range is scaled to the new total, so every process must reshard to
+// the same total. If ctx is done before the new shards are ready, they are disconnected and the current shards are
+// left running.
+func (sm *ShardManager) Reshard(ctx context.Context, newTotal int) error {
+	if sm.cluster != nil {
+		return ErrReshardCluster
+	}
+
+	if newTotal < 1 {
+		return fmt.Errorf("invalid shard count %d", newTotal)
+	}
+
+	sm.reshardLock.Lock()
+	defer sm.reshardLock.Unlock()
+
+	current := sm.shardCount()
+
+	next := ShardCount{
+		Total:   newTotal,
+		Lowest:  current.Lowest * newTotal / current.Total,
+		Highest: current.Highest * newTotal / current.Total,
+	}
+
+	logrus.Infof("Resharding from %d to %d shards", current.Total, newTotal)
+
+	shards := make(map[int]*Shard, next.Highest-next.Lowest)
+	for i := next.Lowest; i < next.Highest; i++ {
+		shard := newShard(sm, sm.Token, i, newTotal)
+		shard.dispatchMode = dispatchHold
+		shards[i] = &shard
+	}
+
+	dedupe := newReshardDedupe(shards, newTotal)
+	for _, shard := range shards {
+		shard.reshard = dedupe
+	}
+
+	currentShards := sm.ShardList()
+	for _, shard := range currentShards {
+		shard.setReshard(dedupe)
+	}
+
+	// Stored sessions are for the current shard count, so the new shards identify
+	for _, shard := range shards {
+		sm.goroutines.Go(shard.EnsureConnect)
+	}
+
+	if err := waitForShards(ctx, sm.ctx, shards); err != nil {
+		for _, shard := range shards {
+			shard.setDispatchMode(dispatchDiscard)
+			shard.cancel()
+			_ = shard.closeConnection(websocket.StatusNormalClosure, "resharding cancelled")
+		}
+
+		for _, shard := range currentShards {
+			shard.endReshard(dedupe)
+		}
+
+		return err
+	}
+
+	// Swap the shard count and the shards together, so that ShardForGuild never mixes the two sets
+	sm.shardsLock.Lock()
+	previous := sm.Shards
+	sm.Shards = shards
+	sm.ShardOptions.ShardCount = next
+	sm.shardsLock.Unlock()
+
+	// The current shards stop dispatching before the new shards are released, so that every event that the current
+	// shards did not dispatch is dispatched by the new shards, and none is dispatched by both
+	for _, shard := range previous {
+		shard.setDispatchMode(dispatchDiscard)
+	}
+
+	for _, shard := range shards {
+		shard.release()
+	}
+
+	// The new shards may not have been sent every event that the current shards dispatched yet
+	time.AfterFunc(reshardDedupeWindow, func() {
+		for _, shard := range shards {
+			shard.endReshard(dedupe)
+		}
+	})
+
+	for _, shard := range previous {
+		shard.cancel()
+		if err := shard.closeConnection(websocket.StatusNormalClosure, "resharding"); err != nil {
+			logrus.Warnf("shard %d: Error whilst closing connection: %s", shard.ShardId, err.Error())
+		}
+
+		// Sessions of shards that are still running will be overwritten when they are next saved
+		if _, ok := shards[shard.ShardId]; !ok && sm.ShardOptions.SessionStore != nil {
+			if err := sm.ShardOptions.SessionStore.Delete(ctx, shard.ShardId); err != nil {
+				logrus.Warnf("shard %d: Error whilst deleting session: %s", shard.ShardId, err.Error())
+			}
+		}
+	}
+
+	logrus.Infof("Resharded to %d shards", newTotal)
+	return nil
+}
+
+// waitForShards blocks until every shard is ready, or either context is done
+func waitForShards(ctx, managerCtx context.Context, shards map[int]*Shard) error {
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+
+	for {
+		ready := true
+		for _, shard := range shards {
+			if !shard.Ready() {
+				ready = false
+				break
+			}
+		}
+
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-managerCtx.Done():
+			return ErrShutdown
+		case <-ticker.C:
+		}
+	}
+}
+
+// reshardDedupeWindow is how long events are deduplicated for after the new shards are released, as a new shard may be
+// sent an event after a current shard dispatched it
+const reshardDedupeWindow = time.Second * 10
+
+// reshardDedupe matches the events dispatched by the current shards against those received by the new shards whilst
+// resharding, so that each event is passed to the user's listeners by whichever set receives it first, and only once.
+type reshardDedupe struct {
+	shards map[int]*Shard // the new shards
+	total  int
+
+	lock         sync.Mutex
+	identified   map[int]struct{}          // new shards that have identified, and so may be sent any event from now on
+	dispatched   map[eventKey]int          // events dispatched by the current shards that no new shard has received yet
+	undispatched map[eventKey][]*heldEvent // events held by the new shards that no current shard has dispatched yet
+}
+
+// eventKey identifies an event across sessions, whose sequence numbers differ
+type eventKey struct {
+	eventType events.EventType
+	guildId   uint64
+	hash      uint64 // of the payload
+}
+
+type heldEvent struct {
+	*Event
+	dispatched bool // whether a current shard dispatched the event after it was held
+}
+
+func newReshardDedupe(shards map[int]*Shard, total int) *reshardDedupe {
+	return &reshardDedupe{
+		shards:       shards,
+		total:        total,
+		identified:   make(map[int]struct{}),
+		dispatched:   make(map[eventKey]int),
+		undispatched: make(map[eventKey][]*heldEvent),
+	}
+}
+
+func newEventKey(e *Event) eventKey {
+	hash := fnv.New64a()
+	_, _ = hash.Write(e.Data)
+
+	return eventKey{
+		eventType: e.Type,
+		guildId:   e.GuildId,
+		hash:      hash.Sum64(),
+	}
+}
+
+func (d *reshardDedupe) identifying(s *Shard) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.identified[s.ShardId] = struct{}{}
+}
+
+// hold is called as a new shard receives an event whilst it is held. It returns a copy of the event to be passed to the
+// user's listeners once the shard is released, or nil if a current shard has already dispatched the event.
+func (d *reshardDedupe) hold(e *Event) *heldEvent {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	key := newEventKey(e)
+	if d.dispatched[key] > 0 {
+		d.dispatched[key]--
+		return nil
+	}
+
+	// The payload is decoded again on release, rather than keeping the decoded event in memory whilst the shard is held
+	held := &heldEvent{
+		Event: &Event{
+			Type:          e.Type,
+			Sequence:      e.Sequence,
+			GuildId:       e.GuildId,
+			Data:          e.Data,
+			syntheticType: e.syntheticType,
+		},
+	}
+
+	d.undispatched[key] = append(d.undispatched[key], held)
+	return held
+}
+
+// dispatching is called as a shard dispatches an event normally, and returns false if the event must not be passed to
+// the user's listeners. Events dispatched by a current shard are recorded, so that a new shard does not dispatch them
+// again, and events that a new shard receives after it is released are dropped if a current shard dispatched them.
+func (d *reshardDedupe) dispatching(s *Shard, e *Event) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	key := newEventKey(e)
+
+	if d.shards[s.ShardId] == s {
+		if d.dispatched[key] > 0 {
+			d.dispatched[key]--
+			return false
+		}
+
+		return true
+	}
+
+	// Events that are not from a guild, such as DMs, are sent to shard 0
+	if _, ok := d.identified[int((e.GuildId>>22)%uint64(d.total))]; !ok {
+		return true
+	}
+
+	if undispatched := d.undispatched[key]; len(undispatched) > 0 {
+		undispatched[0].dispatched = true
+
+		if len(undispatched) == 1 {
+			delete(d.undispatched, key)
+		} else {
+			d.undispatched[key] = undispatched[1:]
+		}
+	} else {
+		d.dispatched[key]++
+	}
+
+	return true
+}
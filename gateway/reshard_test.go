@@ -0,0 +1,197 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReshard(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the new shards wait for the identify rate limit")
+	}
+
+	// With 2 shards, each guild belongs to a different shard
+	guildA, guildB := uint64(1<<22), uint64(2<<22)
+
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		Guilds: []uint64{guildA, guildB},
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	// Events must only be dispatched by shards that are in ShardList
+	var lock sync.Mutex
+	var unlistedShards int
+	checkListed := func(s *Shard) {
+		for _, shard := range sm.ShardList() {
+			if shard == s {
+				return
+			}
+		}
+
+		lock.Lock()
+		unlistedShards++
+		lock.Unlock()
+	}
+
+	readies := make(chan int, 3)
+	On(sm, func(s *Shard, e *events.Ready) {
+		checkListed(s)
+		readies <- s.ShardId
+	})
+
+	type received struct {
+		messageId uint64
+		shard     *Shard
+	}
+
+	messages := make(chan received, 16)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		checkListed(s)
+		messages <- received{messageId: e.Id, shard: s}
+	})
+
+	message := func(messageId, guildId uint64) map[string]interface{} {
+		return map[string]interface{}{
+			"id":       strconv.FormatUint(messageId, 10),
+			"guild_id": strconv.FormatUint(guildId, 10),
+		}
+	}
+
+	dispatchMessage := func(messageId, guildId uint64) {
+		require.NoError(t, server.Dispatch("MESSAGE_CREATE", message(messageId, guildId)))
+	}
+
+	// dispatchToSet sends a message to only the current shards, or only the new shards
+	dispatchToSet := func(shardCount int, messageId, guildId uint64) {
+		require.NoError(t, server.DispatchShardCount(shardCount, "MESSAGE_CREATE", message(messageId, guildId)))
+	}
+
+	// expectMessages asserts that every message is received exactly once, and returns the shards that received them
+	expectMessages := func(messageIds ...uint64) map[uint64]*Shard {
+		shards := make(map[uint64]*Shard)
+		for range messageIds {
+			select {
+			case message := <-messages:
+				require.NotContains(t, shards, message.messageId, "message %d was received twice", message.messageId)
+				shards[message.messageId] = message.shard
+			case <-time.After(time.Second * 5):
+				t.Fatal("timed out waiting for MESSAGE_CREATE")
+			}
+		}
+
+		select {
+		case message := <-messages:
+			t.Fatalf("received an unexpected MESSAGE_CREATE %d", message.messageId)
+		case <-time.After(time.Millisecond * 200):
+		}
+
+		for _, messageId := range messageIds {
+			require.Contains(t, shards, messageId)
+		}
+
+		return shards
+	}
+
+	sm.Connect()
+	waitForReady(t, sm)
+	require.Equal(t, 0, <-readies)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	resharded := make(chan error, 1)
+	go func() {
+		resharded <- sm.Reshard(ctx, 2)
+	}()
+
+	// Whilst the first new shard is connected, but the second is waiting to identify, both sets receive the messages
+	require.Eventually(t, func() bool {
+		return server.Identifies() == 2
+	}, time.Second*10, time.Millisecond*10)
+
+	dispatchMessage(1, guildA)
+	dispatchMessage(2, guildB)
+
+	// Either new shard may identify first
+	heldGuild := guildA
+	if server.Identified(0, 2) {
+		heldGuild = guildB
+	}
+
+	// Message 5 has not been received by the current shard yet, so it is held until the new shards are released.
+	// Message 6 is received by the new shard first, but is dispatched by the current shard, so it is not held, and
+	// message 7 has not been received by the new shard yet, so it must not be dispatched again when it is.
+	dispatchToSet(2, 5, heldGuild)
+	dispatchToSet(2, 6, heldGuild)
+	dispatchToSet(1, 6, heldGuild)
+	dispatchToSet(1, 7, heldGuild)
+
+	senders := expectMessages(1, 2, 6, 7)
+	require.Equal(t, 0, senders[1].ShardId)
+	require.Equal(t, 1, senders[1].shardTotal, "messages must be dispatched by the current shard during resharding")
+	require.Equal(t, 1, senders[2].shardTotal, "messages must be dispatched by the current shard during resharding")
+
+	require.NoError(t, <-resharded)
+	require.Equal(t, 3, server.Identifies())
+	require.Equal(t, ShardCount{Total: 2, Lowest: 0, Highest: 2}, sm.shardCount())
+
+	var shardIds []int
+	for _, shard := range sm.ShardList() {
+		require.True(t, shard.Ready())
+		require.Equal(t, 2, shard.shardTotal)
+		shardIds = append(shardIds, shard.ShardId)
+	}
+
+	sort.Ints(shardIds)
+	require.Equal(t, []int{0, 1}, shardIds)
+
+	// The caches of the new shards were populated whilst they were being brought up
+	shardA := sm.ShardForGuild(guildA)
+	require.Equal(t, 1, shardA.ShardId)
+
+	guild, err := shardA.Cache.GetGuild(context.Background(), guildA)
+	require.NoError(t, err)
+	require.Equal(t, "Guild 4194304", guild.Name)
+
+	// The held READYs are dispatched once the new shards have replaced the old one
+	readyShards := []int{waitForShardId(t, readies), waitForShardId(t, readies)}
+	sort.Ints(readyShards)
+	require.Equal(t, []int{0, 1}, readyShards)
+
+	// Only the held message that the current shard did not dispatch is dispatched by the new shard
+	senders = expectMessages(5)
+	require.Same(t, sm.ShardForGuild(heldGuild), senders[5])
+
+	// Message 7 reaches the new shard after it was released
+	dispatchToSet(2, 7, heldGuild)
+	expectMessages()
+
+	dispatchMessage(3, guildA)
+	dispatchMessage(4, guildB)
+
+	senders = expectMessages(3, 4)
+	require.Same(t, shardA, senders[3])
+	require.Same(t, sm.ShardForGuild(guildB), senders[4])
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Zero(t, unlistedShards)
+}
+
+func waitForShardId(t *testing.T, ch chan int) int {
+	select {
+	case shardId := <-ch:
+		return shardId
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for event")
+		return 0
+	}
+}
@@ -20,10 +20,13 @@ import (
 func (s *Shard) GetChannel(ctx context.Context, channelId uint64) (channel.Channel, error) {
 	if s.Cache.Options().Channels {
 		if cached, err := s.Cache.GetChannel(ctx, channelId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("channel", true)
 			return cached, nil
 		} else if err != cache.ErrNotFound {
 			return channel.Channel{}, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("channel", false)
 	}
 
 	ch, err := rest.GetChannel(ctx, s.Token, s.ShardManager.RateLimiter, channelId)
@@ -237,10 +240,13 @@ func (s *Shard) CreatePrivateThread(ctx context.Context, channelId uint64, name
 func (s *Shard) ListGuildEmojis(ctx context.Context, guildId uint64) ([]emoji.Emoji, error) {
 	if s.Cache.Options().Emojis && s.Cache.Options().Guilds {
 		if emojis, err := s.Cache.GetGuildEmojis(ctx, guildId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("emoji", true)
 			return emojis, nil
 		} else if err != cache.ErrNotFound {
 			return nil, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("emoji", false)
 	}
 
 	emojis, err := rest.ListGuildEmojis(ctx, s.Token, s.ShardManager.RateLimiter, guildId)
@@ -260,10 +266,13 @@ func (s *Shard) ListGuildEmojis(ctx context.Context, guildId uint64) ([]emoji.Em
 func (s *Shard) GetGuildEmoji(ctx context.Context, guildId uint64, emojiId uint64) (emoji.Emoji, error) {
 	if s.Cache.Options().Emojis {
 		if e, err := s.Cache.GetEmoji(ctx, emojiId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("emoji", true)
 			return e, nil
 		} else if err != cache.ErrNotFound {
 			return emoji.Emoji{}, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("emoji", false)
 	}
 
 	e, err := rest.GetGuildEmoji(ctx, s.Token, s.ShardManager.RateLimiter, guildId, emojiId)
@@ -295,10 +304,13 @@ func (s *Shard) CreateGuild(ctx context.Context, data rest.CreateGuildData) (gui
 func (s *Shard) GetGuild(ctx context.Context, guildId uint64) (guild.Guild, error) {
 	if s.Cache.Options().Guilds {
 		if cached, err := s.Cache.GetGuild(ctx, guildId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("guild", true)
 			return cached, nil
 		} else if err != cache.ErrNotFound {
 			return guild.Guild{}, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("guild", false)
 	}
 
 	g, err := rest.GetGuild(ctx, s.Token, s.ShardManager.RateLimiter, guildId)
@@ -330,10 +342,13 @@ func (s *Shard) DeleteGuild(ctx context.Context, guildId uint64) error {
 func (s *Shard) GetGuildChannels(ctx context.Context, guildId uint64) ([]channel.Channel, error) {
 	if s.Cache.Options().Channels {
 		if cached, err := s.Cache.GetGuildChannels(ctx, guildId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("channel", true)
 			return cached, nil
 		} else if err != cache.ErrNotFound {
 			return nil, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("channel", false)
 	}
 
 	channels, err := rest.GetGuildChannels(ctx, s.Token, s.ShardManager.RateLimiter, guildId)
@@ -361,10 +376,13 @@ func (s *Shard) ModifyGuildChannelPositions(ctx context.Context, guildId uint64,
 func (s *Shard) GetGuildMember(ctx context.Context, guildId, userId uint64) (member.Member, error) {
 	if s.Cache.Options().Members {
 		if cached, err := s.Cache.GetMember(ctx, guildId, userId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("member", true)
 			return cached, nil
 		} else if err != cache.ErrNotFound {
 			return member.Member{}, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("member", false)
 	}
 
 	m, err := rest.GetGuildMember(ctx, s.Token, s.ShardManager.RateLimiter, guildId, userId)
@@ -468,10 +486,13 @@ func (s *Shard) RemoveGuildBan(ctx context.Context, guildId, userId uint64) erro
 func (s *Shard) GetGuildRoles(ctx context.Context, guildId uint64) ([]guild.Role, error) {
 	if s.Cache.Options().Roles {
 		if cached, err := s.Cache.GetGuildRoles(ctx, guildId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("role", true)
 			return cached, nil
 		} else if err != cache.ErrNotFound {
 			return nil, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("role", false)
 	}
 
 	roles, err := rest.GetGuildRoles(ctx, s.Token, s.ShardManager.RateLimiter, guildId)
@@ -564,11 +585,14 @@ func (s *Shard) DeleteInvite(ctx context.Context, inviteCode string) (invite.Inv
 
 func (s *Shard) GetCurrentUser(ctx context.Context) (user.User, error) {
 	if cached, err := s.Cache.GetSelf(ctx); err == nil {
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("self", true)
 		return cached, nil
 	} else if err != cache.ErrNotFound {
 		return user.User{}, err
 	}
 
+	s.ShardManager.ShardOptions.Metrics.CacheAccess("self", false)
+
 	self, err := rest.GetCurrentUser(ctx, s.Token, s.ShardManager.RateLimiter)
 	if err != nil {
 		return user.User{}, err
@@ -584,10 +608,13 @@ func (s *Shard) GetCurrentUser(ctx context.Context) (user.User, error) {
 func (s *Shard) GetUser(ctx context.Context, userId uint64) (user.User, error) {
 	if s.Cache.Options().Users {
 		if cached, err := s.Cache.GetUser(ctx, userId); err == nil {
+			s.ShardManager.ShardOptions.Metrics.CacheAccess("user", true)
 			return cached, nil
 		} else if err != cache.ErrNotFound {
 			return user.User{}, err
 		}
+
+		s.ShardManager.ShardOptions.Metrics.CacheAccess("user", false)
 	}
 
 	u, err := rest.GetUser(ctx, s.Token, s.ShardManager.RateLimiter, userId)
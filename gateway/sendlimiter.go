@@ -0,0 +1,124 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// GatewaySendLimit is the number of payloads that may be sent per GatewaySendInterval on a single connection
+	GatewaySendLimit    = 120
+	GatewaySendInterval = time.Minute
+
+	// HeartbeatReserve is the number of payloads out of GatewaySendLimit that only heartbeats may use, so that other
+	// payloads can never starve a heartbeat and get the connection closed
+	HeartbeatReserve = 5
+
+	// PresenceUpdateLimit is the number of presence updates that may be sent per GatewaySendInterval
+	PresenceUpdateLimit = 5
+)
+
+var ErrSendRateLimited = errors.New("gateway send rate limit reached")
+
+// tokenBucket holds up to capacity tokens, refilled continuously over interval
+type tokenBucket struct {
+	sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per nanosecond
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, interval time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		rate:     float64(capacity) / float64(interval),
+		last:     time.Now(),
+	}
+}
+
+// take consumes a token if doing so leaves at least reserve tokens in the bucket. If not, it returns how long to wait
+// before trying again.
+func (b *tokenBucket) take(reserve int) (bool, time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.last)) * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	required := float64(reserve) + 1
+	if b.tokens >= required {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((required - b.tokens) / b.rate)
+}
+
+func (b *tokenBucket) wait(ctx context.Context, reserve int) error {
+	for {
+		ok, delay := b.take(reserve)
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (b *tokenBucket) reset() {
+	b.Lock()
+	b.tokens = b.capacity
+	b.last = time.Now()
+	b.Unlock()
+}
+
+// sendLimiter enforces Discord's outbound gateway rate limits for a single connection
+type sendLimiter struct {
+	payloads  *tokenBucket
+	presences *tokenBucket
+}
+
+func newSendLimiter() *sendLimiter {
+	return &sendLimiter{
+		payloads:  newTokenBucket(GatewaySendLimit, GatewaySendInterval),
+		presences: newTokenBucket(PresenceUpdateLimit, GatewaySendInterval),
+	}
+}
+
+// reset restores the full allowance, as the limit applies per connection
+func (l *sendLimiter) reset() {
+	l.payloads.reset()
+	l.presences.reset()
+}
+
+// Send waits until payload can be sent without exceeding the gateway rate limit, or until ctx is done
+func (s *Shard) Send(ctx context.Context, payload interface{}) error {
+	if err := s.sendLimiter.payloads.wait(ctx, HeartbeatReserve); err != nil {
+		return err
+	}
+
+	return s.write(ctx, payload)
+}
+
+// TrySend sends payload if it would not exceed the gateway rate limit, and returns ErrSendRateLimited otherwise
+func (s *Shard) TrySend(ctx context.Context, payload interface{}) error {
+	if ok, _ := s.sendLimiter.payloads.take(HeartbeatReserve); !ok {
+		return ErrSendRateLimited
+	}
+
+	return s.write(ctx, payload)
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/objects/user"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketThrottles(t *testing.T) {
+	bucket := newTokenBucket(3, time.Millisecond*300)
+
+	for i := 0; i < 3; i++ {
+		ok, _ := bucket.take(0)
+		require.True(t, ok)
+	}
+
+	ok, delay := bucket.take(0)
+	require.False(t, ok)
+	require.InDelta(t, time.Millisecond*100, delay, float64(time.Millisecond*20))
+
+	// wait blocks until a token has been refilled
+	start := time.Now()
+	require.NoError(t, bucket.wait(context.Background(), 0))
+	require.GreaterOrEqual(t, time.Since(start), time.Millisecond*80)
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	bucket := newTokenBucket(3, time.Minute)
+
+	// Only heartbeats may take the reserved tokens
+	for i := 0; i < 2; i++ {
+		ok, _ := bucket.take(1)
+		require.True(t, ok)
+	}
+
+	ok, _ := bucket.take(1)
+	require.False(t, ok)
+
+	ok, _ = bucket.take(0)
+	require.True(t, ok)
+}
+
+func TestTokenBucketCancelled(t *testing.T) {
+	bucket := newTokenBucket(1, time.Hour)
+
+	ok, _ := bucket.take(0)
+	require.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	require.ErrorIs(t, bucket.wait(ctx, 0), context.Canceled)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestUpdateStatusRateLimited(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+	shard := sm.Shards[0]
+
+	for i := 0; i < PresenceUpdateLimit; i++ {
+		ok, _ := shard.sendLimiter.presences.take(0)
+		require.True(t, ok)
+	}
+
+	status := user.BuildStatus(user.ActivityTypePlaying, "gatewaytest")
+	require.ErrorIs(t, shard.TryUpdateStatus(context.Background(), status), ErrSendRateLimited)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	start := time.Now()
+	require.ErrorIs(t, shard.UpdateStatusContext(ctx, status), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
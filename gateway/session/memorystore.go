@@ -0,0 +1,43 @@
+package session
+
+import (
+	"context"
+	"sync"
+)
+
+type MemoryStore struct {
+	sync.RWMutex
+	sessions map[int]Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[int]Session),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, shardId int) (Session, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	session, ok := s.sessions[shardId]
+	if !ok {
+		return Session{}, ErrNotFound
+	}
+
+	return session, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, shardId int, session Session) error {
+	s.Lock()
+	s.sessions[shardId] = session
+	s.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, shardId int) error {
+	s.Lock()
+	delete(s.sessions, shardId)
+	s.Unlock()
+	return nil
+}
@@ -0,0 +1,41 @@
+package session
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	_, err := store.Get(ctx, 0)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	saved := Session{
+		SessionId:        "abc",
+		Sequence:         10,
+		ResumeGatewayUrl: "wss://resume.example",
+		ShardCount:       2,
+		UpdatedAt:        time.Now(),
+	}
+
+	require.NoError(t, store.Set(ctx, 0, saved))
+
+	loaded, err := store.Get(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, saved, loaded)
+
+	// Sessions are stored per shard
+	_, err = store.Get(ctx, 1)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Delete(ctx, 0))
+	_, err = store.Get(ctx, 0)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a session that does not exist is not an error
+	require.NoError(t, store.Delete(ctx, 0))
+}
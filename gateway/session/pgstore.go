@@ -0,0 +1,75 @@
+package session
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type PgStore struct {
+	*pgxpool.Pool
+}
+
+func NewPgStore(db *pgxpool.Pool) *PgStore {
+	return &PgStore{
+		Pool: db,
+	}
+}
+
+func (s *PgStore) CreateSchema(ctx context.Context) error {
+	_, err := s.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS gateway_sessions(
+	"shard_id" int4 NOT NULL,
+	"session_id" varchar(255) NOT NULL,
+	"sequence" int4 NOT NULL,
+	"resume_gateway_url" varchar(255) NOT NULL,
+	"shard_count" int4 NOT NULL,
+	"updated_at" timestamptz NOT NULL,
+	PRIMARY KEY("shard_id")
+);`)
+
+	return err
+}
+
+func (s *PgStore) Get(ctx context.Context, shardId int) (Session, error) {
+	query := `
+SELECT "session_id", "sequence", "resume_gateway_url", "shard_count", "updated_at"
+FROM gateway_sessions
+WHERE "shard_id" = $1;`
+
+	var session Session
+	err := s.QueryRow(ctx, query, shardId).Scan(
+		&session.SessionId,
+		&session.Sequence,
+		&session.ResumeGatewayUrl,
+		&session.ShardCount,
+		&session.UpdatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return Session{}, ErrNotFound
+	}
+
+	return session, err
+}
+
+func (s *PgStore) Set(ctx context.Context, shardId int, session Session) error {
+	query := `
+INSERT INTO gateway_sessions("shard_id", "session_id", "sequence", "resume_gateway_url", "shard_count", "updated_at")
+VALUES($1, $2, $3, $4, $5, $6)
+ON CONFLICT("shard_id") DO UPDATE SET
+	"session_id" = EXCLUDED."session_id",
+	"sequence" = EXCLUDED."sequence",
+	"resume_gateway_url" = EXCLUDED."resume_gateway_url",
+	"shard_count" = EXCLUDED."shard_count",
+	"updated_at" = EXCLUDED."updated_at";`
+
+	_, err := s.Exec(ctx, query, shardId, session.SessionId, session.Sequence, session.ResumeGatewayUrl,
+		session.ShardCount, session.UpdatedAt)
+	return err
+}
+
+func (s *PgStore) Delete(ctx context.Context, shardId int) error {
+	_, err := s.Exec(ctx, `DELETE FROM gateway_sessions WHERE "shard_id" = $1;`, shardId)
+	return err
+}
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+type RedisStore struct {
+	*redis.Client
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewRedisStore creates a store that keeps sessions for ttl after they were last saved. A ttl of 0 keeps sessions
+// until they are deleted.
+func NewRedisStore(client *redis.Client, keyPrefix string, ttl time.Duration) *RedisStore {
+	return &RedisStore{
+		Client:    client,
+		keyPrefix: keyPrefix,
+		ttl:       ttl,
+	}
+}
+
+func (s *RedisStore) key(shardId int) string {
+	return fmt.Sprintf("%s:session:%d", s.keyPrefix, shardId)
+}
+
+func (s *RedisStore) Get(ctx context.Context, shardId int) (Session, error) {
+	raw, err := s.Client.Get(ctx, s.key(shardId)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return Session{}, ErrNotFound
+		}
+
+		return Session{}, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, shardId int, session Session) error {
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return s.Client.Set(ctx, s.key(shardId), encoded, s.ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, shardId int) error {
+	return s.Del(ctx, s.key(shardId)).Err()
+}
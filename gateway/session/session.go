@@ -0,0 +1,24 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// Session holds everything required to resume a gateway session
+type Session struct {
+	SessionId        string    `json:"session_id"`
+	Sequence         int       `json:"sequence"`
+	ResumeGatewayUrl string    `json:"resume_gateway_url"`
+	ShardCount       int       `json:"shard_count"` // Sessions are only valid for the shard count they were created with
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type Store interface {
+	Get(ctx context.Context, shardId int) (Session, error) // Returns ErrNotFound if there is no session stored
+	Set(ctx context.Context, shardId int, session Session) error
+	Delete(ctx context.Context, shardId int) error
+}
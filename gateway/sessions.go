@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"github.com/rxdn/gdl/gateway/session"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const DefaultSessionSaveInterval = time.Second * 10
+
+// saveSession persists the shard's session to ShardOptions.SessionStore, or deletes the stored session if the shard
+// no longer has one that can be resumed
+func (s *Shard) saveSession(ctx context.Context) error {
+	store := s.ShardManager.ShardOptions.SessionStore
+	if store == nil {
+		return nil
+	}
+
+	s.sessionLock.RLock()
+	s.sequenceLock.RLock()
+	sessionId, resumeGatewayUrl, sequence := s.sessionId, s.resumeGatewayUrl, s.sequenceNumber
+	s.sequenceLock.RUnlock()
+	s.sessionLock.RUnlock()
+
+	if sessionId == "" || sequence == nil {
+		return store.Delete(ctx, s.ShardId)
+	}
+
+	return store.Set(ctx, s.ShardId, session.Session{
+		SessionId:        sessionId,
+		Sequence:         *sequence,
+		ResumeGatewayUrl: resumeGatewayUrl,
+		ShardCount:       s.shardTotal,
+		UpdatedAt:        time.Now(),
+	})
+}
+
+// restoreSession loads a session saved by a previous process, so that the shard resumes instead of identifying
+func (s *Shard) restoreSession(ctx context.Context) error {
+	store := s.ShardManager.ShardOptions.SessionStore
+	if store == nil {
+		return nil
+	}
+
+	stored, err := store.Get(ctx, s.ShardId)
+	if err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if stored.ShardCount != s.shardTotal {
+		logrus.Infof("shard %d: not restoring session created with %d shards", s.ShardId, stored.ShardCount)
+		return nil
+	}
+
+	sequence := stored.Sequence
+
+	s.sequenceLock.Lock()
+	s.sequenceNumber = &sequence
+	s.sequenceLock.Unlock()
+
+	s.setSession(stored.SessionId, stored.ResumeGatewayUrl)
+
+	logrus.Infof("shard %d: restored session from %s", s.ShardId, stored.UpdatedAt)
+	return nil
+}
+
+// SaveSessions persists the session of every shard, so that another process can resume them. It should be called
+// before the process exits.
+func (sm *ShardManager) SaveSessions(ctx context.Context) error {
+	var errs []error
+	for _, shard := range sm.ShardList() {
+		if err := shard.saveSession(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (sm *ShardManager) saveSessionsPeriodically() {
+	ticker := time.NewTicker(sm.ShardOptions.SessionSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sm.ctx.Done():
+			return // Shutdown saves sessions itself once shards have stopped reading
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(sm.ctx, sm.ShardOptions.SessionSaveInterval)
+			if err := sm.SaveSessions(ctx); err != nil {
+				logrus.Warnf("Error whilst saving sessions: %s", err.Error())
+			}
+			cancel()
+		}
+	}
+}
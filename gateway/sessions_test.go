@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/cache"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/session"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newSessionTestShardManager(t *testing.T, server *gatewaytest.Server, store session.Store) *ShardManager {
+	return NewShardManager(testToken, ShardOptions{
+		ShardCount:     ShardCount{Total: 1, Lowest: 0, Highest: 1},
+		CacheFactory:   cache.MemoryCacheFactory(cache.CacheOptions{}),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		SessionStore:   store,
+		GatewayUrl:     server.URL,
+	})
+}
+
+func TestSessionResumedByNextProcess(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	store := session.NewMemoryStore()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	first := newSessionTestShardManager(t, server, store)
+	first.Connect()
+	waitForReady(t, first)
+
+	// A resumable shutdown saves the sessions, rather than invalidating them
+	require.NoError(t, first.Shutdown(ctx, true))
+
+	saved, err := store.Get(ctx, 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, saved.SessionId)
+	require.Equal(t, 1, saved.ShardCount)
+
+	second := newSessionTestShardManager(t, server, store)
+	t.Cleanup(func() {
+		require.NoError(t, second.Shutdown(context.Background(), false))
+	})
+
+	second.Connect()
+
+	require.Eventually(t, func() bool {
+		return server.Resumes() == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, 1, server.Identifies())
+}
+
+func TestSessionNotRestoredWithDifferentShardCount(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	store := session.NewMemoryStore()
+
+	require.NoError(t, store.Set(context.Background(), 0, session.Session{
+		SessionId:  "stale",
+		Sequence:   5,
+		ShardCount: 2,
+		UpdatedAt:  time.Now(),
+	}))
+
+	sm := newSessionTestShardManager(t, server, store)
+	t.Cleanup(func() {
+		require.NoError(t, sm.Shutdown(context.Background(), false))
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	require.Equal(t, 1, server.Identifies())
+	require.Equal(t, 0, server.Resumes())
+}
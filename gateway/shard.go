@@ -10,70 +10,176 @@ import (
 	"github.com/rxdn/gdl/gateway/payloads"
 	"github.com/rxdn/gdl/gateway/payloads/events"
 	"github.com/rxdn/gdl/objects/user"
-	"github.com/rxdn/gdl/utils"
 	"github.com/sirupsen/logrus"
-	"github.com/tatsuworks/czlib"
 	"log"
-	"net/http"
+	"math/rand"
 	"nhooyr.io/websocket"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	DefaultGatewayUrl     = "wss://gateway.discord.gg"
+	DefaultGatewayVersion = 9
+)
+
 type Shard struct {
 	ShardManager *ShardManager
 	Token        string
 	ShardId      int
+	shardTotal   int // the shard count that the shard identifies with, which differs from ShardCount.Total during Reshard
 
 	state     State
 	stateLock sync.RWMutex
 
-	WebSocket  *websocket.Conn
-	context    context.Context
-	zLibReader wrappedReader
-	readLock   *sync.Mutex
+	WebSocket    *websocket.Conn
+	context      context.Context // cancelled on Shutdown, or when the shard is handed off to another worker
+	cancel       context.CancelFunc
+	readLock     *sync.Mutex
+	readBuffer   bytes.Buffer // Protected by readLock
+	decompressor Decompressor // Protected by readLock
 
 	sequenceLock   sync.RWMutex
 	sequenceNumber *int
 
-	lastHeartbeat     int64 // Millis
+	heartbeatLock     sync.RWMutex
 	heartbeatInterval int
-	hasDoneHeartbeat  bool
+	heartbeatsSent    []sentHeartbeat // Heartbeats awaiting an ACK, oldest first
+	heartbeatRtt      time.Duration
+	killHeartbeat     chan struct{}
+
+	sessionLock      sync.RWMutex
+	sessionId        string
+	resumeGatewayUrl string
+
+	memberRequestsLock sync.Mutex
+	memberRequests     map[string]*memberRequest
+
+	voiceRequestsLock sync.Mutex
+	voiceRequests     map[uint64]*VoiceConnectionFuture
+
+	selfId atomic.Uint64 // The bot's user ID, from READY
+
+	sendLimiter *sendLimiter
+
+	lastEvent  atomic.Int64 // Unix nanos
+	reconnects atomic.Int64
+	connected  atomic.Bool // Whether the shard has ever connected
+
+	// Whether the read loop is blocked on a full dispatch queue, and when it was last unblocked (Unix nanos)
+	dispatchBlocked   atomic.Bool
+	dispatchUnblocked atomic.Int64
+
+	guildsLock sync.RWMutex
+	guilds     map[uint64]struct{}
 
-	lastHeartbeatAcknowledgement int64 // Millis
-	heartbeatLock                sync.RWMutex
-	killHeartbeat                chan struct{}
+	readinessLock sync.Mutex
+	readiness     readiness
 
-	sessionId string
+	dispatchLock  sync.Mutex
+	dispatchMode  dispatchMode
+	held          []*heldEvent   // Dispatches received whilst in dispatchHold, for the user's listeners
+	readyDeferred bool           // Whether the shard became ready whilst in dispatchHold
+	reshard       *reshardDedupe // Set on both sets of shards whilst resharding
 
 	Cache cache.Cache
 }
 
 func NewShard(shardManager *ShardManager, token string, shardId int) Shard {
+	return newShard(shardManager, token, shardId, shardManager.shardCount().Total)
+}
+
+func newShard(shardManager *ShardManager, token string, shardId, shardTotal int) Shard {
 	cache := shardManager.ShardOptions.CacheFactory()
+	ctx, cancel := context.WithCancel(shardManager.ctx)
 
 	return Shard{
-		ShardManager:                 shardManager,
-		Token:                        token,
-		ShardId:                      shardId,
-		state:                        DEAD,
-		context:                      context.Background(),
-		lastHeartbeatAcknowledgement: utils.GetCurrentTimeMillis(),
-		Cache:                        cache,
-		readLock:                     &sync.Mutex{},
+		ShardManager:   shardManager,
+		Token:          token,
+		ShardId:        shardId,
+		shardTotal:     shardTotal,
+		state:          DEAD,
+		context:        ctx,
+		cancel:         cancel,
+		Cache:          cache,
+		readLock:       &sync.Mutex{},
+		memberRequests: make(map[string]*memberRequest),
+		voiceRequests:  make(map[uint64]*VoiceConnectionFuture),
+		sendLimiter:    newSendLimiter(),
+		guilds:         make(map[uint64]struct{}),
+		readiness: readiness{
+			pending:     make(map[uint64]struct{}),
+			unavailable: make(map[uint64]struct{}),
+		},
 	}
 }
 
+// EnsureConnect calls Connect until it succeeds, backing off between attempts. It gives up if Discord closes the
+// connection with a fatal close code, in which case Hooks.FatalHook is called.
 func (s *Shard) EnsureConnect() {
-	if err := s.Connect(); err != nil {
-		logrus.Warnf("shard %d: Error whilst connecting: %s", s.ShardId, err.Error())
-		time.Sleep(500 * time.Millisecond)
-		s.EnsureConnect()
+	backoff := newBackoff(time.Second, time.Minute)
+
+	for {
+		// Don't reconnect if the shard manager is shutting down
+		if s.context.Err() != nil {
+			return
+		}
+
+		err := s.Connect()
+		if err == nil {
+			return
+		}
+
+		var closeErr CloseError
+		if errors.As(err, &closeErr) {
+			if closeErr.Code.Fatal() {
+				s.fatal(closeErr)
+				return
+			}
+
+			if !closeErr.Code.Resumable() {
+				s.setSession("", "")
+			}
+		}
+
+		delay := backoff.next()
+		logrus.Warnf("shard %d: Error whilst connecting, retrying in %s: %s", s.ShardId, delay, err.Error())
+		if !s.sleep(delay) {
+			return
+		}
+	}
+}
+
+// reconnect calls EnsureConnect in the background after waiting for delay
+func (s *Shard) reconnect(delay time.Duration) {
+	s.ShardManager.goroutines.Go(func() {
+		if s.sleep(delay) {
+			s.EnsureConnect()
+		}
+	})
+}
+
+// sleep waits for d, and returns false if the shard manager was shut down in the meantime
+func (s *Shard) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-s.context.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
 }
 
 func (s *Shard) Connect() error {
+	if s.context.Err() != nil {
+		return ErrShutdown
+	}
+
 	logrus.Infof("shard %d: Starting", s.ShardId)
 
 	// Connect to Discord
@@ -91,25 +197,41 @@ func (s *Shard) Connect() error {
 	s.state = CONNECTING
 	s.stateLock.Unlock()
 
-	// initialise zlib reader
-	zlibReader, err := czlib.NewReader(bytes.NewReader(nil))
+	// The compression context is per connection
+	decompressor, err := s.ShardManager.ShardOptions.Compression.NewDecompressor()
 	if err != nil {
+		s.stateLock.Lock()
+		s.state = DEAD
+		s.stateLock.Unlock()
 		return err
 	}
 
-	s.zLibReader = wrappedReader{
-		ReadCloser: zlibReader,
-		closeChan:  make(chan struct{}),
+	s.readLock.Lock()
+	if s.decompressor != nil {
+		if err := s.decompressor.Close(); err != nil {
+			logrus.Warnf("shard %d: Error whilst closing decompressor: %s", s.ShardId, err.Error())
+		}
 	}
-	defer zlibReader.Close()
+	s.decompressor = decompressor
+	s.readLock.Unlock()
 
-	headers := http.Header{}
-	headers.Add("accept-encoding", "zlib")
+	identifyUrl := s.ShardManager.ShardOptions.GatewayUrl
 
-	conn, _, err := websocket.Dial(s.context, "wss://gateway.discord.gg/?v=9&encoding=json&compress=zlib-stream", &websocket.DialOptions{
-		CompressionMode: websocket.CompressionContextTakeover,
-		HTTPHeader:      headers,
-	})
+	s.sessionLock.RLock()
+	resumeUrl := s.resumeGatewayUrl
+	s.sessionLock.RUnlock()
+
+	var conn *websocket.Conn
+	if s.canResume() && resumeUrl != "" {
+		conn, err = s.dial(resumeUrl)
+		if err != nil {
+			logrus.Warnf("shard %d: Error whilst dialing resume gateway %s, falling back to %s: %s", s.ShardId, resumeUrl, identifyUrl, err.Error())
+		}
+	}
+
+	if conn == nil {
+		conn, err = s.dial(identifyUrl)
+	}
 
 	if err != nil {
 		s.stateLock.Lock()
@@ -120,7 +242,17 @@ func (s *Shard) Connect() error {
 
 	conn.SetReadLimit(4294967296)
 
+	s.stateLock.Lock()
 	s.WebSocket = conn
+	s.stateLock.Unlock()
+
+	// If the shard was stopped whilst dialing, the connection was set after it was killed, so must be closed here
+	if s.context.Err() != nil {
+		s.Kill()
+		return ErrShutdown
+	}
+
+	s.sendLimiter.reset()
 
 	// Read hello
 	if err := s.read(); err != nil {
@@ -129,48 +261,131 @@ func (s *Shard) Connect() error {
 		return err
 	}
 
-	if s.sessionId == "" || s.sequenceNumber == nil {
-		s.identify()
+	if s.canResume() {
+		err = s.resume()
 	} else {
-		s.resume()
+		err = s.identify()
+	}
+
+	// EnsureConnect retries with a new connection, so that the shard is never left connected without a session
+	if err != nil {
+		s.Kill()
+		return err
 	}
 
 	logrus.Infof("shard %d: Connected", s.ShardId)
 
+	if s.connected.Swap(true) {
+		s.reconnects.Add(1)
+		s.ShardManager.ShardOptions.Metrics.Reconnected(s.ShardId)
+	}
+
 	s.stateLock.Lock()
 	s.state = CONNECTED
 	s.stateLock.Unlock()
 
-	go func() {
-		for {
-			// Verify that we are still connected
-			s.stateLock.RLock()
-			state := s.state
-			s.stateLock.RUnlock()
-			if state != CONNECTED {
-				break
-			}
+	if s.ShardManager.ShardOptions.Hooks.ShardConnectedHook != nil {
+		s.ShardManager.ShardOptions.Hooks.ShardConnectedHook(s)
+	}
+
+	s.ShardManager.goroutines.Go(func() {
+		s.readLoop(conn)
+	})
+
+	return nil
+}
 
-			// Read
-			if err := s.read(); err != nil {
-				logrus.Warnf("shard %d: Error whilst reading payload: %s", s.ShardId, err.Error())
+// readLoop reads payloads until conn is closed or replaced by a new connection
+func (s *Shard) readLoop(conn *websocket.Conn) {
+	for {
+		if !s.isCurrentConnection(conn) {
+			return
+		}
 
-				s.stateLock.Lock()
-				state := s.state
-				s.stateLock.Unlock()
+		if err := s.read(); err != nil {
+			logrus.Warnf("shard %d: Error whilst reading payload: %s", s.ShardId, err.Error())
 
-				if state == CONNECTED {
-					s.Kill()
-					s.EnsureConnect()
-				}
+			// If the shard was killed or reconnected elsewhere, there is nothing left for us to do
+			if s.isCurrentConnection(conn) {
+				s.handleDisconnect(err)
 			}
+
+			return
 		}
-	}()
+	}
+}
 
-	return nil
+func (s *Shard) isCurrentConnection(conn *websocket.Conn) bool {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+	return s.state == CONNECTED && s.WebSocket == conn
 }
 
-func (s *Shard) identify() {
+func (s *Shard) handleDisconnect(err error) {
+	_, _ = s.closeWebSocket(4000, "unknown")
+
+	var closeErr CloseError
+	isCloseErr := errors.As(err, &closeErr)
+
+	s.disconnected(closeErr.Code, err)
+
+	if isCloseErr {
+		if closeErr.Code.Fatal() {
+			s.fatal(closeErr)
+			return
+		}
+
+		if !closeErr.Code.Resumable() {
+			logrus.Infof("shard %d: session cannot be resumed after close code %d", s.ShardId, closeErr.Code)
+			s.setSession("", "")
+		}
+	}
+
+	s.EnsureConnect()
+}
+
+func (s *Shard) fatal(err error) {
+	logrus.Errorf("shard %d: Fatal gateway error, not reconnecting: %s", s.ShardId, err.Error())
+
+	if s.ShardManager.ShardOptions.Hooks.FatalHook != nil {
+		s.ShardManager.ShardOptions.Hooks.FatalHook(s, err)
+	}
+}
+
+func (s *Shard) dial(baseUrl string) (*websocket.Conn, error) {
+	options := s.ShardManager.ShardOptions
+
+	// ETF is not supported, as payloads are passed to listeners as raw JSON, and transcoding costs more than decoding
+	url := fmt.Sprintf("%s/?v=%d&encoding=json", strings.TrimSuffix(baseUrl, "/"), options.GatewayVersion)
+	if compression := options.Compression.Name(); compression != "" {
+		url += "&compress=" + compression
+	}
+
+	conn, _, err := websocket.Dial(s.context, url, &websocket.DialOptions{
+		CompressionMode: websocket.CompressionContextTakeover,
+	})
+
+	return conn, err
+}
+
+func (s *Shard) canResume() bool {
+	s.sessionLock.RLock()
+	defer s.sessionLock.RUnlock()
+
+	s.sequenceLock.RLock()
+	defer s.sequenceLock.RUnlock()
+
+	return s.sessionId != "" && s.sequenceNumber != nil
+}
+
+func (s *Shard) setSession(sessionId, resumeGatewayUrl string) {
+	s.sessionLock.Lock()
+	s.sessionId = sessionId
+	s.resumeGatewayUrl = resumeGatewayUrl
+	s.sessionLock.Unlock()
+}
+
+func (s *Shard) identify() error {
 	// call hook
 	if s.ShardManager.ShardOptions.Hooks.IdentifyHook != nil {
 		s.ShardManager.ShardOptions.Hooks.IdentifyHook(s)
@@ -179,7 +394,7 @@ func (s *Shard) identify() {
 	// build payload
 	identify := payloads.NewIdentify(
 		s.ShardId,
-		s.ShardManager.ShardOptions.ShardCount.Total,
+		s.shardTotal,
 		s.Token,
 		s.ShardManager.ShardOptions.Presence,
 		s.ShardManager.ShardOptions.GuildSubscriptions,
@@ -188,43 +403,64 @@ func (s *Shard) identify() {
 
 	// wait for ratelimit
 	if err := s.ShardManager.RateLimiter.IdentifyWait(s.ShardId); err != nil {
-		logrus.Warnf("shard %d: Error whilst waiting on identify ratelimit: %s", s.ShardId, err.Error())
+		return fmt.Errorf("error whilst waiting on identify ratelimit: %w", err)
 	}
 
-	if err := s.write(identify); err != nil {
-		logrus.Warnf("shard %d: Error whilst sending Identify: %s", s.ShardId, err.Error())
-		s.identify()
+	s.identifying()
+
+	if err := s.Send(s.context, identify); err != nil {
+		return fmt.Errorf("error whilst sending Identify: %w", err)
 	}
+
+	return nil
 }
 
-func (s *Shard) resume() {
+func (s *Shard) resume() error {
+	s.sessionLock.RLock()
 	s.sequenceLock.RLock()
 	resume := payloads.NewResume(s.Token, s.sessionId, *s.sequenceNumber)
 	s.sequenceLock.RUnlock()
+	s.sessionLock.RUnlock()
 
 	logrus.Infof("shard %d: Resuming", s.ShardId)
 
-	if err := s.write(resume); err != nil {
-		logrus.Warnf("shard %d: Error whilst sending Resume: %s", s.ShardId, err.Error())
-		s.identify()
+	if err := s.Send(s.context, resume); err != nil {
+		return fmt.Errorf("error whilst sending Resume: %w", err)
 	}
+
+	return nil
 }
 
 func (s *Shard) read() error {
 	defer func() {
 		if r := recover(); r != nil {
-			logrus.Warnf("Recovered panic while reading: %s", r)
-			s.Kill()
-			go s.EnsureConnect()
+			s.ShardManager.ShardOptions.PanicReporter(s, "", r, debug.Stack())
+			_ = s.closeConnection(4000, "panic whilst handling payload")
+			s.reconnect(0)
 		}
 	}()
 
 	data, err := s.readData()
 	if err != nil {
+		if closeErr, ok := closeErrorFrom(err); ok {
+			return closeErr
+		}
+
 		return err
 	}
 
 	payload, err := payloads.NewPayload(data)
+	if err != nil {
+		// A single malformed frame does not mean the connection is unusable, so skip it rather than reconnecting
+		logrus.Warnf("shard %d: Error whilst decoding payload, skipping: %s", s.ShardId, err.Error())
+		return nil
+	}
+
+	if recorder := s.ShardManager.ShardOptions.Recorder; recorder != nil {
+		if err := recorder.Record(s.ShardId, payload); err != nil {
+			logrus.Warnf("shard %d: Error whilst recording payload: %s", s.ShardId, err.Error())
+		}
+	}
 
 	// Handle new sequence number
 	if payload.SequenceNumber != nil {
@@ -237,8 +473,23 @@ func (s *Shard) read() error {
 	switch payload.Opcode {
 	case 0: // Event
 		{
+			s.lastEvent.Store(time.Now().UnixNano())
+			s.ShardManager.ShardOptions.Metrics.EventReceived(s.ShardId, payload.EventName)
+
 			event := events.EventType(payload.EventName)
-			go s.ExecuteEvent(event, payload.Data)
+			if event == events.READY {
+				s.beginReady(payload.Data)
+			}
+
+			e := newEvent(event, payload.SequenceNumber, payload.Data)
+			s.received(e)
+			s.dispatch(e)
+		}
+	case 1: // Heartbeat request
+		{
+			if err := s.heartbeat(false); err != nil {
+				logrus.Warnf("shard %d: Error whilst replying to heartbeat request: %s", s.ShardId, err.Error())
+			}
 		}
 	case 7: // Reconnect
 		{
@@ -248,15 +499,27 @@ func (s *Shard) read() error {
 				s.ShardManager.ShardOptions.Hooks.ReconnectHook(s)
 			}
 
-			s.Kill()
-			go s.EnsureConnect()
+			_ = s.closeConnection(4000, "reconnect requested")
+			s.reconnect(0)
 		}
 	case 9: // Invalid session
 		{
-			logrus.Infof("shard %d: received invalid session payload from discord", s.ShardId)
-			s.Kill()
-			s.sessionId = ""
-			go s.EnsureConnect()
+			var resumable bool
+			if err := json.Unmarshal(payload.Data, &resumable); err != nil {
+				logrus.Warnf("shard %d: error decoding invalid session payload: %s", s.ShardId, err.Error())
+			}
+
+			logrus.Infof("shard %d: received invalid session payload from discord (resumable: %t)", s.ShardId, resumable)
+			_ = s.closeConnection(4000, "invalid session")
+
+			if resumable {
+				s.reconnect(0)
+			} else {
+				s.setSession("", "")
+
+				// Discord asks that we wait a random amount of time between 1 and 5 seconds before identifying
+				s.reconnect(time.Second + time.Duration(rand.Int63n(int64(4*time.Second))))
+			}
 		}
 	case 10: // Hello
 		{
@@ -265,11 +528,18 @@ func (s *Shard) read() error {
 				return err
 			}
 
+			kill := make(chan struct{})
+
+			s.heartbeatLock.Lock()
 			s.heartbeatInterval = hello.EventData.Interval
-			s.killHeartbeat = make(chan struct{})
+			s.heartbeatsSent = nil
+			s.killHeartbeat = kill
+			s.heartbeatLock.Unlock()
 
-			ticker := time.NewTicker(time.Duration(int32(s.heartbeatInterval)) * time.Millisecond)
-			go s.CountdownHeartbeat(ticker)
+			interval := time.Duration(hello.EventData.Interval) * time.Millisecond
+			s.ShardManager.goroutines.Go(func() {
+				s.countdownHeartbeat(interval, kill)
+			})
 		}
 	case 11: // Heartbeat ACK
 		{
@@ -279,9 +549,9 @@ func (s *Shard) read() error {
 				return err
 			}
 
-			s.heartbeatLock.Lock()
-			s.lastHeartbeatAcknowledgement = utils.GetCurrentTimeMillis()
-			s.heartbeatLock.Unlock()
+			if rtt, ok := s.heartbeatAcknowledged(); ok {
+				s.ShardManager.ShardOptions.Metrics.HeartbeatLatency(s.ShardId, rtt)
+			}
 		}
 	}
 
@@ -292,64 +562,96 @@ func (s *Shard) readData() ([]byte, error) {
 	s.readLock.Lock()
 	defer s.readLock.Unlock()
 
-	if s.WebSocket == nil {
+	conn := s.currentConnection()
+	if conn == nil {
 		return nil, errors.New("websocket is nil")
 	}
 
-	_, reader, err := s.WebSocket.Reader(context.Background())
+	_, reader, err := conn.Reader(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
-	// decompress
-	s.zLibReader.Reset(reader)
-	data, err := s.zLibReader.Read()
+	// The buffers are reused for every message, so data is only valid until the next call
+	s.readBuffer.Reset()
+	if _, err := s.readBuffer.ReadFrom(reader); err != nil {
+		return nil, err
+	}
 
-	return data, err
+	return s.decompressor.Decompress(s.readBuffer.Bytes())
 }
 
-func (s *Shard) write(payload interface{}) error {
+func (s *Shard) write(ctx context.Context, payload interface{}) error {
 	encoded, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	return s.writeRaw(encoded)
+	return s.writeRaw(ctx, encoded)
 }
 
-func (s *Shard) writeRaw(data []byte) error {
-	if s.WebSocket == nil {
+func (s *Shard) writeRaw(ctx context.Context, data []byte) error {
+	conn := s.currentConnection()
+	if conn == nil {
 		msg := fmt.Sprintf("shard %d: WS is closed", s.ShardId)
 		logrus.Warn(msg)
 		return errors.New(msg)
 	}
 
-	err := s.WebSocket.Write(s.context, websocket.MessageText, data)
+	err := conn.Write(ctx, websocket.MessageText, data)
 
 	return err
 }
 
+// currentConnection returns the websocket, which is replaced by Connect and cleared by Kill whilst other goroutines are
+// reading and writing
+func (s *Shard) currentConnection() *websocket.Conn {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+	return s.WebSocket
+}
+
+// Kill closes the connection with a close code that allows the session to be resumed
 func (s *Shard) Kill() error {
+	return s.closeConnection(4000, "unknown")
+}
+
+// closeConnection closes the websocket and stops heartbeating. Close codes 1000 and 1001 invalidate the session, any
+// other code allows it to be resumed. If the shard was connected, ShardDisconnectedHook is called with code.
+func (s *Shard) closeConnection(code websocket.StatusCode, reason string) error {
+	connected, err := s.closeWebSocket(code, reason)
+	if connected {
+		s.disconnected(CloseCode(code), CloseError{
+			Code:   CloseCode(code),
+			Reason: reason,
+		})
+	}
+
+	return err
+}
+
+// closeWebSocket closes the websocket and stops heartbeating, and returns whether the shard was connected
+func (s *Shard) closeWebSocket(code websocket.StatusCode, reason string) (bool, error) {
 	if s.ShardManager.ShardOptions.Debug {
 		debug.PrintStack()
 	}
 
 	logrus.Infof("killing shard %d", s.ShardId)
 
-	go func() {
-		s.killHeartbeat <- struct{}{}
-	}()
-
-	if err := s.zLibReader.Close(); err != nil {
-		logrus.Warnf("shard %d: error closing zlib: %s", s.ShardId, err.Error())
+	s.heartbeatLock.Lock()
+	if s.killHeartbeat != nil {
+		close(s.killHeartbeat)
+		s.killHeartbeat = nil
 	}
+	s.heartbeatLock.Unlock()
 
 	s.stateLock.Lock()
+	connected := s.state == CONNECTED && s.WebSocket != nil
 	s.state = DISCONNECTING
 
 	var err error
 	if s.WebSocket != nil {
-		err = s.WebSocket.Close(4000, "unknown")
+		err = s.WebSocket.Close(code, reason)
 	}
 
 	s.WebSocket = nil
@@ -359,9 +661,37 @@ func (s *Shard) Kill() error {
 
 	logrus.Infof("killed shard %d", s.ShardId)
 
-	return err
+	return connected, err
+}
+
+func (s *Shard) disconnected(code CloseCode, err error) {
+	if s.ShardManager.ShardOptions.Hooks.ShardDisconnectedHook != nil {
+		s.ShardManager.ShardOptions.Hooks.ShardDisconnectedHook(s, code, err)
+	}
 }
 
+// UpdateStatus waits until the presence update can be sent without exceeding the gateway rate limits, or until the
+// shard manager is shut down
 func (s *Shard) UpdateStatus(data user.UpdateStatus) error {
-	return s.write(payloads.NewPresenceUpdate(data))
+	return s.UpdateStatusContext(s.context, data)
+}
+
+// UpdateStatusContext waits until the presence update can be sent without exceeding the gateway rate limits, or until
+// ctx is done
+func (s *Shard) UpdateStatusContext(ctx context.Context, data user.UpdateStatus) error {
+	if err := s.sendLimiter.presences.wait(ctx, 0); err != nil {
+		return err
+	}
+
+	return s.Send(ctx, payloads.NewPresenceUpdate(data))
+}
+
+// TryUpdateStatus sends the presence update if it would not exceed the gateway rate limits, and returns
+// ErrSendRateLimited otherwise
+func (s *Shard) TryUpdateStatus(ctx context.Context, data user.UpdateStatus) error {
+	if ok, _ := s.sendLimiter.presences.take(0); !ok {
+		return ErrSendRateLimited
+	}
+
+	return s.TrySend(ctx, payloads.NewPresenceUpdate(data))
 }
@@ -0,0 +1,514 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/cache"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testToken = "test-token"
+
+// newTestServer starts a fake gateway that is closed once the test and its shard manager have finished, so that the
+// shard manager does not see the server going away and reconnect whilst shutting down
+func newTestServer(t *testing.T, options gatewaytest.Options) *gatewaytest.Server {
+	server := gatewaytest.NewServer(options)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestShardManager(t *testing.T, server *gatewaytest.Server, hooks Hooks) *ShardManager {
+	sm := NewShardManager(testToken, ShardOptions{
+		ShardCount: ShardCount{
+			Total:   1,
+			Lowest:  0,
+			Highest: 1,
+		},
+		CacheFactory:   cache.MemoryCacheFactory(cache.CacheOptions{Guilds: true}),
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		Hooks:          hooks,
+		GatewayUrl:     server.URL,
+	})
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		require.NoError(t, sm.Shutdown(ctx, false))
+	})
+
+	return sm
+}
+
+func waitForReady(t *testing.T, sm *ShardManager) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	require.NoError(t, sm.WaitForReady(ctx))
+}
+
+func TestIdentifyAndDispatch(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		Guilds: []uint64{1, 2},
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	messages := make(chan *events.MessageCreate, 1)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		messages <- e
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	require.Equal(t, 1, server.Identifies())
+	require.ElementsMatch(t, []uint64{}, sm.UnavailableGuilds())
+
+	guild, err := sm.Shards[0].Cache.GetGuild(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, "Guild 2", guild.Name)
+
+	require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{
+		"id":         "100",
+		"channel_id": "200",
+		"content":    "hello",
+	}))
+
+	select {
+	case message := <-messages:
+		require.Equal(t, uint64(100), message.Id)
+		require.Equal(t, "hello", message.Content)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for MESSAGE_CREATE")
+	}
+}
+
+func TestRawEvent(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	raw := make(chan *events.RawEvent, 8)
+	OnRaw(sm, func(s *Shard, e *events.RawEvent) {
+		if e.EventName == "MESSAGE_POLL_VOTE_ADD" {
+			raw <- e
+		}
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	require.NoError(t, server.Dispatch("MESSAGE_POLL_VOTE_ADD", map[string]interface{}{
+		"message_id": "100",
+		"answer_id":  1,
+	}))
+
+	select {
+	case e := <-raw:
+		require.Equal(t, 0, e.Opcode)
+		require.NotNil(t, e.Sequence)
+		require.Greater(t, *e.Sequence, 1) // READY has the first sequence number
+		require.JSONEq(t, `{"message_id":"100","answer_id":1}`, string(e.Data))
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for MESSAGE_POLL_VOTE_ADD")
+	}
+}
+
+func TestMalformedPayloadSkipped(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	raw := make(chan *events.RawEvent, 8)
+	OnRaw(sm, func(s *Shard, e *events.RawEvent) {
+		raw <- e
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	server.SendRaw([]byte(`{"op":0,"t":"MESSAGE_CREATE","d":`))
+	require.NoError(t, server.Dispatch("TYPING_START", map[string]interface{}{"channel_id": "300", "user_id": "400"}))
+
+	// The malformed frame is neither dispatched nor causes the shard to reconnect
+	for {
+		select {
+		case e := <-raw:
+			require.NotEmpty(t, e.EventName)
+			if e.EventName != "TYPING_START" {
+				continue
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for TYPING_START")
+		}
+
+		break
+	}
+
+	require.Equal(t, 1, server.Identifies())
+	require.Equal(t, 0, server.Resumes())
+}
+
+func TestResumeAfterReconnect(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	resumed := make(chan struct{}, 1)
+	sm := newTestShardManager(t, server, Hooks{
+		ShardResumedHook: func(*Shard) {
+			resumed <- struct{}{}
+		},
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	server.Reconnect()
+
+	select {
+	case <-resumed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the session to be resumed")
+	}
+
+	require.Equal(t, 1, server.Identifies())
+	require.Equal(t, 1, server.Resumes())
+
+	// The session is resumed using resume_gateway_url from READY
+	require.Equal(t, 1, server.ResumeRequests())
+}
+
+func TestResumeFallsBackToGatewayUrl(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	resumed := make(chan struct{}, 1)
+	sm := newTestShardManager(t, server, Hooks{
+		ShardResumedHook: func(*Shard) {
+			resumed <- struct{}{}
+		},
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	server.CloseResumeURL()
+	server.Reconnect()
+
+	select {
+	case <-resumed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the session to be resumed")
+	}
+
+	require.Equal(t, 1, server.Identifies())
+	require.Equal(t, 1, server.Resumes())
+	require.Equal(t, 0, server.ResumeRequests())
+}
+
+func TestResumeReplaysMissedDispatches(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	disconnected := make(chan CloseCode, 1)
+	sm := newTestShardManager(t, server, Hooks{
+		ShardDisconnectedHook: func(_ *Shard, code CloseCode, _ error) {
+			disconnected <- code
+		},
+	})
+
+	typing := make(chan *events.TypingStart, 1)
+	On(sm, func(s *Shard, e *events.TypingStart) {
+		typing <- e
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	// Resumable close code, the dispatch is sent whilst the shard is disconnected
+	server.CloseConnections(4000, "unknown error")
+	require.Equal(t, CloseUnknownError, <-disconnected)
+	require.NoError(t, server.Dispatch("TYPING_START", map[string]interface{}{"channel_id": "300", "user_id": "400"}))
+
+	select {
+	case e := <-typing:
+		require.Equal(t, uint64(300), e.ChannelId)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the missed dispatch to be replayed")
+	}
+
+	require.Equal(t, 1, server.Identifies())
+	require.Equal(t, 1, server.Resumes())
+}
+
+func TestDisconnectedHookOnSelfInitiatedClose(t *testing.T) {
+	tests := []struct {
+		name       string
+		disconnect func(server *gatewaytest.Server)
+		reason     string
+	}{
+		{
+			name:       "reconnect",
+			disconnect: (*gatewaytest.Server).Reconnect,
+			reason:     "reconnect requested",
+		},
+		{
+			name: "invalid session",
+			disconnect: func(server *gatewaytest.Server) {
+				server.InvalidateSessions(true)
+			},
+			reason: "invalid session",
+		},
+		{
+			name: "zombie",
+			disconnect: func(server *gatewaytest.Server) {
+				server.SetHeartbeatAck(false)
+			},
+			reason: "heartbeat ACK not received",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestServer(t, gatewaytest.Options{
+				Token:             testToken,
+				HeartbeatInterval: time.Millisecond * 100,
+			})
+
+			type disconnect struct {
+				code CloseCode
+				err  error
+			}
+
+			disconnected := make(chan disconnect, 8)
+			sm := newTestShardManager(t, server, Hooks{
+				ShardDisconnectedHook: func(_ *Shard, code CloseCode, err error) {
+					disconnected <- disconnect{code, err}
+				},
+			})
+
+			sm.Connect()
+			waitForReady(t, sm)
+
+			test.disconnect(server)
+
+			select {
+			case d := <-disconnected:
+				require.Equal(t, CloseUnknownError, d.code)
+				require.Equal(t, CloseError{Code: CloseUnknownError, Reason: test.reason}, d.err)
+			case <-time.After(time.Second * 5):
+				t.Fatal("timed out waiting for ShardDisconnectedHook")
+			}
+		})
+	}
+}
+
+func TestResumableInvalidSession(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	server.InvalidateSessions(true)
+
+	require.Eventually(t, func() bool {
+		return server.Resumes() == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	require.Equal(t, 1, server.Identifies())
+}
+
+func TestHeartbeat(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:             testToken,
+		HeartbeatInterval: time.Millisecond * 100,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	require.Eventually(t, func() bool {
+		return server.Heartbeats() >= 3
+	}, time.Second*5, time.Millisecond*10)
+
+	// Heartbeats are acknowledged, so the connection should not have been restarted
+	require.Equal(t, 1, server.Identifies())
+	require.Equal(t, 0, server.Resumes())
+}
+
+func TestZombieConnection(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:             testToken,
+		HeartbeatInterval: time.Millisecond * 100,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	require.Eventually(t, func() bool {
+		return sm.Shards[0].HeartbeatRtt() > 0
+	}, time.Second*5, time.Millisecond*10)
+
+	// Without an ACK, the connection is considered zombied, and the shard reconnects and resumes
+	server.SetHeartbeatAck(false)
+	require.Eventually(t, func() bool {
+		return server.Resumes() >= 1
+	}, time.Second*5, time.Millisecond*10)
+
+	require.Equal(t, 1, server.Identifies())
+}
+
+func TestHeartbeatWithSlowListener(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:             testToken,
+		HeartbeatInterval: time.Millisecond * 50,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+	sm.dispatcher = newDispatcher(DispatcherOptions{Workers: 1, QueueSize: 1})
+
+	messages := make(chan uint64, 8)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		time.Sleep(time.Millisecond * 200)
+		messages <- e.Id
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	// The read loop blocks on the full queue for several heartbeat intervals, so ACKs are read late
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{
+			"id":         strconv.Itoa(i),
+			"channel_id": "200",
+		}))
+	}
+
+	for i := 1; i <= 5; i++ {
+		select {
+		case id := <-messages:
+			require.Equal(t, uint64(i), id)
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for MESSAGE_CREATE")
+		}
+	}
+
+	require.Equal(t, 1, server.Identifies())
+	require.Equal(t, 0, server.Resumes())
+	require.Positive(t, sm.DispatcherStats().Overflows)
+}
+
+func TestHeartbeatRequest(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	// The heartbeat interval is far longer than the test, so any heartbeat is in reply to the request
+	heartbeats := server.Heartbeats()
+	server.RequestHeartbeat()
+
+	require.Eventually(t, func() bool {
+		return server.Heartbeats() > heartbeats && sm.Shards[0].HeartbeatRtt() > 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestHeartbeatRequestBeforeTick(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	// The reply to the request has not been acknowledged when the interval elapses
+	server.SetHeartbeatAck(false)
+	heartbeats := server.Heartbeats()
+	server.RequestHeartbeat()
+
+	require.Eventually(t, func() bool {
+		return server.Heartbeats() > heartbeats
+	}, time.Second*5, time.Millisecond*10)
+
+	shard := sm.Shards[0]
+	require.True(t, shard.beat(), "an unacknowledged requested heartbeat should not be treated as a missed ACK")
+
+	// The scheduled heartbeat is still unacknowledged at the next tick
+	require.False(t, shard.beat())
+	require.Eventually(t, func() bool {
+		return server.Resumes() == 1
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestFatalCloseCode(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: "a different token",
+	})
+	fatal := make(chan error, 1)
+	sm := newTestShardManager(t, server, Hooks{
+		FatalHook: func(_ *Shard, err error) {
+			fatal <- err
+		},
+	})
+
+	sm.Connect()
+
+	select {
+	case err := <-fatal:
+		var closeErr CloseError
+		require.ErrorAs(t, err, &closeErr)
+		require.Equal(t, CloseAuthenticationFailed, closeErr.Code)
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for the fatal close code")
+	}
+
+	require.Equal(t, 0, server.Identifies())
+	require.Eventually(t, func() bool {
+		return server.Connections() == 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestIdentifyFailureRetries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the second identify waits for the identify rate limit")
+	}
+
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+
+	var identifies, connects atomic.Int32
+	sm := newTestShardManager(t, server, Hooks{
+		// Closing the connection before the first Identify is sent makes sending it fail
+		IdentifyHook: func(s *Shard) {
+			if identifies.Add(1) == 1 {
+				s.Kill()
+			}
+		},
+		ShardConnectedHook: func(s *Shard) {
+			connects.Add(1)
+		},
+	})
+
+	sm.Connect()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+	require.NoError(t, sm.WaitForReady(ctx))
+
+	// The failed attempt is retried with a new connection, and is not reported as connected
+	require.Equal(t, int32(2), identifies.Load())
+	require.Equal(t, int32(1), connects.Load())
+	require.Equal(t, 1, server.Identifies())
+}
@@ -1,12 +1,25 @@
 package gateway
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/rxdn/gdl/metrics"
+	"github.com/rxdn/gdl/rest"
 	"github.com/rxdn/gdl/rest/ratelimit"
 	"github.com/rxdn/gdl/rest/request"
+	"github.com/sirupsen/logrus"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
+)
+
+var (
+	ErrSessionStartLimitExhausted = errors.New("session start limit exhausted")
+	ErrShutdown                   = errors.New("shard manager has been shut down")
 )
 
 type ShardManager struct {
@@ -15,9 +28,37 @@ type ShardManager struct {
 	RateLimiter *ratelimit.Ratelimiter
 
 	ShardOptions ShardOptions
-	Shards       map[int]*Shard
 
-	EventBus *events.EventBus
+	// Shards is replaced, rather than modified, when shards are added or removed in cluster mode, and by Reshard, which
+	// also replaces ShardOptions.ShardCount. In either case, use ShardList or ShardForGuild instead of reading it
+	// directly.
+	Shards     map[int]*Shard
+	shardsLock sync.RWMutex
+
+	EventBus          *events.EventBus
+	internalListeners *listenerRegistry // the library's own listeners, which run before the middleware chain
+	listeners         *listenerRegistry
+	rawListeners      *rawHandlerSet
+	waiters           *listenerRegistry // WaitFor and Collector handlers, which are called as events are read
+	dispatcher        *dispatcher
+
+	middlewareLock sync.RWMutex
+	middleware     []Middleware
+
+	ctx        context.Context // cancelled on Shutdown
+	cancel     context.CancelFunc
+	goroutines goroutineGroup
+
+	readyLock      sync.Mutex
+	allShardsReady bool
+	allReady       chan struct{} // closed the first time every shard is ready
+	allReadyOnce   sync.Once
+
+	reshardLock sync.Mutex // serialises Reshard
+
+	checkIntents bool // whether listeners are checked against the intents as they are registered
+
+	cluster *clusterWorker // nil unless ShardOptions.Cluster is set
 }
 
 func NewShardManager(token string, shardOptions ShardOptions) *ShardManager {
@@ -25,17 +66,55 @@ func NewShardManager(token string, shardOptions ShardOptions) *ShardManager {
 		shardOptions.LargeShardingBuckets = 1
 	}
 
+	if shardOptions.GatewayUrl == "" {
+		shardOptions.GatewayUrl = DefaultGatewayUrl
+	}
+
+	if shardOptions.SessionSaveInterval == 0 {
+		shardOptions.SessionSaveInterval = DefaultSessionSaveInterval
+	}
+
+	if shardOptions.GatewayVersion == 0 {
+		shardOptions.GatewayVersion = DefaultGatewayVersion
+	}
+
+	if shardOptions.Compression == nil {
+		shardOptions.Compression = ZlibStream
+	}
+
+	if shardOptions.PanicReporter == nil {
+		shardOptions.PanicReporter = LogPanic
+	}
+
+	if shardOptions.Metrics == nil {
+		shardOptions.Metrics = metrics.Noop{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	manager := &ShardManager{
 		Token:        token,
-		RateLimiter:  ratelimit.NewRateLimiter(shardOptions.RateLimitStore, shardOptions.LargeShardingBuckets),
+		RateLimiter:  newRateLimiter(shardOptions, shardOptions.LargeShardingBuckets),
 		ShardOptions: shardOptions,
 		EventBus:     events.NewEventBus(),
+		listeners:    newListenerRegistry(),
+		rawListeners: &rawHandlerSet{},
+		waiters:      newListenerRegistry(),
+		dispatcher:   newDispatcher(shardOptions.Dispatcher),
+		ctx:          ctx,
+		cancel:       cancel,
+		allReady:     make(chan struct{}),
 	}
 
+	// In cluster mode, shards are created as they are assigned to this worker
 	manager.Shards = make(map[int]*Shard)
-	for i := shardOptions.ShardCount.Lowest; i < shardOptions.ShardCount.Highest; i++ {
-		shard := NewShard(manager, token, i)
-		manager.Shards[i] = &shard
+	if shardOptions.Cluster != nil {
+		manager.cluster = newClusterWorker(manager, *shardOptions.Cluster)
+	} else {
+		for i := shardOptions.ShardCount.Lowest; i < shardOptions.ShardCount.Highest; i++ {
+			shard := NewShard(manager, token, i)
+			manager.Shards[i] = &shard
+		}
 	}
 
 	if shardOptions.Hooks.RestHook != nil {
@@ -43,27 +122,160 @@ func NewShardManager(token string, shardOptions ShardOptions) *ShardManager {
 	}
 
 	RegisterCacheListeners(manager)
+	registerMemberRequestListeners(manager)
+	registerStatusListeners(manager)
+	registerReadinessListeners(manager)
+	registerMetricsListeners(manager)
+
+	// Every shard uses the same cache options. In cluster mode there are no shards yet, so build a cache to check them
+	manager.checkCacheIntents(shardOptions.CacheFactory().Options())
+
+	// Listeners registered from now on are the user's, and run within the middleware chain
+	manager.internalListeners = manager.listeners
+	manager.listeners = newListenerRegistry()
+	manager.checkIntents = true
 
 	return manager
 }
 
+// newRateLimiter creates a ratelimiter that reports the manager's REST requests to ShardOptions.Metrics, so that
+// managers in the same process can report to different collectors
+func newRateLimiter(shardOptions ShardOptions, largeShardingBuckets int) *ratelimit.Ratelimiter {
+	rateLimiter := ratelimit.NewRateLimiter(shardOptions.RateLimitStore, largeShardingBuckets)
+	rateLimiter.Metrics = shardOptions.Metrics
+	return rateLimiter
+}
+
+// NewAutoShardManager fetches GET /gateway/bot and uses the recommended shard count, gateway URL and identify
+// concurrency for any of ShardCount, GatewayUrl and LargeShardingBuckets that have not been set.
+func NewAutoShardManager(ctx context.Context, token string, shardOptions ShardOptions) (*ShardManager, error) {
+	var rateLimiter *ratelimit.Ratelimiter
+	if shardOptions.RateLimitStore != nil {
+		rateLimiter = newRateLimiter(shardOptions, 1)
+	}
+
+	gatewayBot, err := rest.GetGatewayBot(ctx, token, rateLimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	if shardOptions.ShardCount.Total == 0 {
+		shardOptions.ShardCount.Total = gatewayBot.Shards
+	}
+
+	if shardOptions.ShardCount.Highest == 0 {
+		shardOptions.ShardCount.Highest = shardOptions.ShardCount.Total
+	}
+
+	if shardOptions.GatewayUrl == "" {
+		shardOptions.GatewayUrl = gatewayBot.Url
+	}
+
+	if shardOptions.LargeShardingBuckets == 0 {
+		shardOptions.LargeShardingBuckets = gatewayBot.SessionStartLimit.MaxConcurrency
+	}
+
+	limit := gatewayBot.SessionStartLimit
+	required := shardOptions.ShardCount.Highest - shardOptions.ShardCount.Lowest
+	if limit.Remaining < required {
+		resetAfter := time.Duration(limit.ResetAfter) * time.Millisecond
+		return nil, fmt.Errorf("%w: %d of %d remaining, %d required, resets in %s",
+			ErrSessionStartLimitExhausted, limit.Remaining, limit.Total, required, resetAfter)
+	}
+
+	return NewShardManager(token, shardOptions), nil
+}
+
 func (sm *ShardManager) Connect() {
+	sm.dispatcher.start()
+
+	if sm.cluster != nil {
+		sm.goroutines.Go(sm.cluster.run)
+	} else {
+		for _, shard := range sm.ShardList() {
+			sm.connectShard(shard)
+		}
+	}
+
+	if sm.ShardOptions.SessionStore != nil {
+		sm.goroutines.Go(sm.saveSessionsPeriodically)
+	}
+}
+
+// connectShard restores the shard's saved session, if there is one, and connects it in the background
+func (sm *ShardManager) connectShard(shard *Shard) {
+	sm.goroutines.Go(func() {
+		ctx, cancel := context.WithTimeout(shard.context, time.Second*5)
+		if err := shard.restoreSession(ctx); err != nil {
+			logrus.Warnf("shard %d: Error whilst restoring session: %s", shard.ShardId, err.Error())
+		}
+		cancel()
+
+		shard.EnsureConnect()
+	})
+}
+
+// ShardList returns the shards that are currently running, in no particular order
+func (sm *ShardManager) ShardList() []*Shard {
+	sm.shardsLock.RLock()
+	defer sm.shardsLock.RUnlock()
+
+	shards := make([]*Shard, 0, len(sm.Shards))
 	for _, shard := range sm.Shards {
-		go shard.EnsureConnect()
+		shards = append(shards, shard)
+	}
+
+	return shards
+}
+
+// setShards replaces the shards map with a copy that has been modified by fn, so that the map is never modified
+// whilst another goroutine may be reading it
+func (sm *ShardManager) setShards(fn func(shards map[int]*Shard)) {
+	sm.shardsLock.Lock()
+	defer sm.shardsLock.Unlock()
+
+	shards := make(map[int]*Shard, len(sm.Shards))
+	for shardId, shard := range sm.Shards {
+		shards[shardId] = shard
 	}
+
+	fn(shards)
+	sm.Shards = shards
 }
 
+// RegisterListeners registers listeners of the form func(*Shard, *events.X). Prefer On, which is checked at
+// compile time and returns an Unsubscribe handle.
 func (sm *ShardManager) RegisterListeners(listeners ...interface{}) {
 	for _, listener := range listeners {
+		if _, ok := registerListener(sm, listener); !ok {
+			logrus.Warnf("ignoring listener with unsupported signature %T", listener)
+			continue
+		}
+
 		sm.EventBus.RegisterListener(listener)
 	}
 }
 
+func (sm *ShardManager) DispatcherStats() DispatcherStats {
+	return sm.dispatcher.stats()
+}
+
 func (sm *ShardManager) ShardForGuild(guildId uint64) *Shard {
+	// The shard count and the shards are read together, as Reshard replaces both at once
+	sm.shardsLock.RLock()
+	defer sm.shardsLock.RUnlock()
+
 	shardId := int((guildId >> 22) % uint64(sm.ShardOptions.ShardCount.Total))
 	return sm.Shards[shardId]
 }
 
+// shardCount returns ShardOptions.ShardCount, which is replaced by Reshard
+func (sm *ShardManager) shardCount() ShardCount {
+	sm.shardsLock.RLock()
+	defer sm.shardsLock.RUnlock()
+	return sm.ShardOptions.ShardCount
+}
+
 func (sm *ShardManager) WaitForInterrupt() {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
@@ -3,8 +3,11 @@ package gateway
 import (
 	"github.com/rxdn/gdl/cache"
 	"github.com/rxdn/gdl/gateway/intents"
+	"github.com/rxdn/gdl/gateway/session"
+	"github.com/rxdn/gdl/metrics"
 	"github.com/rxdn/gdl/objects/user"
 	"github.com/rxdn/gdl/rest/ratelimit"
+	"time"
 )
 
 type ShardOptions struct {
@@ -16,7 +19,18 @@ type ShardOptions struct {
 	Hooks                Hooks
 	Debug                bool
 	Intents              []intents.Intent
-	LargeShardingBuckets int // defaults to 1. don't touch unless discord tell you to
+	Dispatcher           DispatcherOptions
+	Metrics              metrics.Metrics // defaults to metrics.Noop. also receives REST metrics
+	ChunkLargeGuilds     bool            // request the full member list of large guilds. requires the GuildMembers intent
+	SessionStore         session.Store   // persists sessions so they can be resumed after a restart. use with a persistent cache
+	SessionSaveInterval  time.Duration   // defaults to 10 seconds
+	LargeShardingBuckets int             // defaults to 1, or max_concurrency when using NewAutoShardManager
+	GatewayUrl           string          // defaults to wss://gateway.discord.gg
+	GatewayVersion       int             // defaults to 9
+	Compression          Compression     // defaults to ZlibStream
+	Recorder             *Recorder       // records every payload received, so that it can be replayed with a ReplayShardManager
+	Cluster              *ClusterOptions // run shards assigned by a coordinator, instead of ShardCount.Lowest to ShardCount.Highest
+	PanicReporter        PanicReporter   // called when a listener panics, or the shard panics whilst reading. defaults to LogPanic
 }
 
 type ShardCount struct {
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"github.com/rxdn/gdl/cache"
+	"github.com/sirupsen/logrus"
+	"nhooyr.io/websocket"
+)
+
+// Shutdown disconnects every shard and waits until all of the shard manager's goroutines have exited, including
+// event handlers that are still running. If resumable is true, the connections are closed in a way that allows
+// another process to resume the sessions, and the sessions are saved to ShardOptions.SessionStore. Otherwise, the
+// sessions are invalidated. Shutdown returns ctx's error if it is done before everything has stopped.
+func (sm *ShardManager) Shutdown(ctx context.Context, resumable bool) error {
+	logrus.Infof("Shutting down shard manager (resumable: %t)", resumable)
+
+	// Stop reconnecting, heartbeating and periodically saving sessions
+	sm.cancel()
+
+	// Closing the connections stops the read loops
+	code, reason := websocket.StatusNormalClosure, "shutting down"
+	if resumable {
+		code = 4000
+	}
+
+	shards := sm.ShardList()
+	for _, shard := range shards {
+		if err := shard.closeConnection(code, reason); err != nil {
+			logrus.Warnf("shard %d: Error whilst closing connection: %s", shard.ShardId, err.Error())
+		}
+
+		if !resumable {
+			shard.setSession("", "")
+		}
+	}
+
+	if err := sm.goroutines.Wait(ctx); err != nil {
+		return err
+	}
+
+	// Nothing is reading anymore, so no more events can be queued
+	if err := sm.dispatcher.drain(ctx); err != nil {
+		return err
+	}
+
+	// Handlers may have started goroutines of their own, e.g. to chunk guilds
+	if err := sm.goroutines.Wait(ctx); err != nil {
+		return err
+	}
+
+	var errs []error
+	if err := sm.SaveSessions(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Other workers can resume the sessions that were just saved
+	if sm.cluster != nil {
+		if err := sm.cluster.leave(ctx, shards); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, shard := range shards {
+		if flusher, ok := shard.Cache.(cache.Flusher); ok {
+			if err := flusher.Flush(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -2,9 +2,28 @@ package gateway
 
 type State int
 
-const(
+const (
 	CONNECTED State = iota
 	CONNECTING
 	DISCONNECTING
 	DEAD
 )
+
+func (s State) String() string {
+	switch s {
+	case CONNECTED:
+		return "CONNECTED"
+	case CONNECTING:
+		return "CONNECTING"
+	case DISCONNECTING:
+		return "DISCONNECTING"
+	case DEAD:
+		return "DEAD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type ShardStatus struct {
+	ShardId    int           `json:"shard_id"`
+	State      State         `json:"state"`
+	Latency    time.Duration `json:"-"` // Heartbeat round trip time, 0 if no heartbeat has been acknowledged yet
+	LastEvent  time.Time     `json:"last_event"`
+	Reconnects int           `json:"reconnects"`
+	Guilds     int           `json:"guilds"`
+}
+
+func (s ShardStatus) MarshalJSON() ([]byte, error) {
+	type alias ShardStatus
+	return json.Marshal(struct {
+		alias
+		LatencyMillis int64 `json:"latency_ms"`
+	}{
+		alias:         alias(s),
+		LatencyMillis: s.Latency.Milliseconds(),
+	})
+}
+
+func (s *Shard) Status() ShardStatus {
+	s.stateLock.RLock()
+	state := s.state
+	s.stateLock.RUnlock()
+
+	latency := s.HeartbeatRtt()
+
+	var lastEvent time.Time
+	if nanos := s.lastEvent.Load(); nanos != 0 {
+		lastEvent = time.Unix(0, nanos)
+	}
+
+	s.guildsLock.RLock()
+	guilds := len(s.guilds)
+	s.guildsLock.RUnlock()
+
+	return ShardStatus{
+		ShardId:    s.ShardId,
+		State:      state,
+		Latency:    latency,
+		LastEvent:  lastEvent,
+		Reconnects: int(s.reconnects.Load()),
+		Guilds:     guilds,
+	}
+}
+
+// Statuses returns the status of every shard, ordered by shard ID
+func (sm *ShardManager) Statuses() []ShardStatus {
+	shards := sm.ShardList()
+	statuses := make([]ShardStatus, 0, len(shards))
+	for _, shard := range shards {
+		statuses = append(statuses, shard.Status())
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ShardId < statuses[j].ShardId
+	})
+
+	return statuses
+}
+
+// StatusHandler serves the status of every shard as JSON. It responds with 200 if every shard is connected, and 503
+// otherwise, so it can be used as a readiness probe. A shard manager with no shards, e.g. a cluster worker that has not
+// been assigned any yet, is not ready.
+func (sm *ShardManager) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := sm.Statuses()
+
+		ready := len(statuses) > 0
+		for _, status := range statuses {
+			if status.State != CONNECTED {
+				ready = false
+				break
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_ = json.NewEncoder(w).Encode(struct {
+			Ready  bool          `json:"ready"`
+			Shards []ShardStatus `json:"shards"`
+		}{
+			Ready:  ready,
+			Shards: statuses,
+		})
+	})
+}
+
+func (s *Shard) trackGuild(guildId uint64) {
+	s.guildsLock.Lock()
+	s.guilds[guildId] = struct{}{}
+	s.guildsLock.Unlock()
+}
+
+func (s *Shard) untrackGuild(guildId uint64) {
+	s.guildsLock.Lock()
+	delete(s.guilds, guildId)
+	s.guildsLock.Unlock()
+}
+
+func readyGuildsListener(s *Shard, e *events.Ready) {
+	s.guildsLock.Lock()
+	s.guilds = make(map[uint64]struct{}, len(e.Guilds))
+	for _, guild := range e.Guilds {
+		s.guilds[guild.Id] = struct{}{}
+	}
+	s.guildsLock.Unlock()
+}
+
+func guildCreateGuildsListener(s *Shard, e *events.GuildCreate) {
+	s.trackGuild(e.Id)
+}
+
+func guildDeleteGuildsListener(s *Shard, e *events.GuildDelete) {
+	// unavailable guilds are still guilds that we are in
+	if e.Unavailable == nil || !*e.Unavailable {
+		s.untrackGuild(e.Id)
+	}
+}
+
+func registerStatusListeners(sm *ShardManager) {
+	On(sm, readyGuildsListener)
+	On(sm, guildCreateGuildsListener)
+	On(sm, guildDeleteGuildsListener)
+}
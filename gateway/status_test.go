@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type statusResponse struct {
+	Ready  bool `json:"ready"`
+	Shards []struct {
+		ShardId int    `json:"shard_id"`
+		State   string `json:"state"`
+	} `json:"shards"`
+}
+
+func getStatus(t *testing.T, sm *ShardManager) (int, statusResponse) {
+	recorder := httptest.NewRecorder()
+	sm.StatusHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var res statusResponse
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+	return recorder.Code, res
+}
+
+func TestStatusHandlerReady(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	code, res := getStatus(t, sm)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, res.Ready)
+	require.Len(t, res.Shards, 1)
+	require.Equal(t, CONNECTED.String(), res.Shards[0].State)
+}
+
+func TestStatusHandlerNotReady(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	// The shard has not been connected
+	code, res := getStatus(t, sm)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.False(t, res.Ready)
+	require.Len(t, res.Shards, 1)
+	require.NotEqual(t, CONNECTED.String(), res.Shards[0].State)
+}
+
+func TestStatusHandlerNoShards(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.setShards(func(shards map[int]*Shard) {
+		for shardId := range shards {
+			delete(shards, shardId)
+		}
+	})
+
+	code, res := getStatus(t, sm)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.False(t, res.Ready)
+	require.Empty(t, res.Shards)
+}
@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"github.com/rxdn/gdl/gateway/payloads"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+)
+
+var ErrVoiceStateSuperseded = errors.New("voice state update was superseded by a newer update for the same guild")
+
+// VoiceConnectionInfo contains everything required by a voice client to connect to a voice server
+type VoiceConnectionInfo struct {
+	GuildId   uint64
+	ChannelId uint64 // 0 if we left the channel
+	UserId    uint64
+	SessionId string
+	Token     string
+	Endpoint  string
+}
+
+// VoiceConnectionFuture resolves once Discord has sent both our VOICE_STATE_UPDATE and the VOICE_SERVER_UPDATE, or
+// only the VOICE_STATE_UPDATE when leaving a channel.
+type VoiceConnectionFuture struct {
+	shard          *Shard
+	info           VoiceConnectionInfo
+	leaving        bool
+	receivedState  bool
+	receivedServer bool
+
+	err  error
+	done chan struct{}
+}
+
+// Done is closed once the future has resolved
+func (f *VoiceConnectionFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the future has resolved, or ctx is done. If ctx is done first, the future is abandoned, and will
+// not resolve.
+func (f *VoiceConnectionFuture) Wait(ctx context.Context) (VoiceConnectionInfo, error) {
+	select {
+	case <-f.done:
+		return f.info, f.err
+	case <-ctx.Done():
+		f.shard.removeVoiceRequest(f)
+		return VoiceConnectionInfo{}, ctx.Err()
+	}
+}
+
+// UpdateVoiceState joins, moves between or leaves (if channelId is 0) voice channels. ctx only bounds sending the
+// update; use the future to wait for Discord's response. The responses are collected as they are read from the
+// gateway, before they reach the dispatcher, so it is safe to wait for the future from a listener.
+func (s *Shard) UpdateVoiceState(ctx context.Context, guildId, channelId uint64, selfMute, selfDeaf bool) (*VoiceConnectionFuture, error) {
+	future := &VoiceConnectionFuture{
+		shard: s,
+		info: VoiceConnectionInfo{
+			GuildId:   guildId,
+			ChannelId: channelId,
+		},
+		leaving: channelId == 0,
+		done:    make(chan struct{}),
+	}
+
+	s.voiceRequestsLock.Lock()
+	if existing, ok := s.voiceRequests[guildId]; ok {
+		existing.resolve(ErrVoiceStateSuperseded)
+	}
+	s.voiceRequests[guildId] = future
+	s.voiceRequestsLock.Unlock()
+
+	if err := s.Send(ctx, payloads.NewUpdateVoiceState(guildId, channelId, selfMute, selfDeaf)); err != nil {
+		s.removeVoiceRequest(future)
+		return nil, err
+	}
+
+	return future, nil
+}
+
+// removeVoiceRequest stops future from being resolved, unless it has already been superseded by a newer update
+func (s *Shard) removeVoiceRequest(future *VoiceConnectionFuture) {
+	s.voiceRequestsLock.Lock()
+	defer s.voiceRequestsLock.Unlock()
+
+	if s.voiceRequests[future.info.GuildId] == future {
+		delete(s.voiceRequests, future.info.GuildId)
+	}
+}
+
+func (f *VoiceConnectionFuture) resolve(err error) {
+	f.err = err
+	close(f.done)
+}
+
+func (f *VoiceConnectionFuture) complete() bool {
+	if f.leaving {
+		return f.receivedState
+	}
+
+	return f.receivedState && f.receivedServer
+}
+
+// pendingVoiceRequests returns whether any future is waiting for a voice update, so that voice updates can be passed to
+// the dispatcher without being decoded on the read goroutine when none are
+func (s *Shard) pendingVoiceRequests() bool {
+	s.voiceRequestsLock.Lock()
+	defer s.voiceRequestsLock.Unlock()
+	return len(s.voiceRequests) > 0
+}
+
+func resolveVoiceState(s *Shard, e *events.VoiceStateUpdate) {
+	// The ID is read from READY rather than the cache, as this is called on the read goroutine
+	if e.UserId == 0 || e.UserId != s.selfId.Load() {
+		return
+	}
+
+	s.voiceRequestsLock.Lock()
+	defer s.voiceRequestsLock.Unlock()
+
+	future, ok := s.voiceRequests[e.GuildId]
+	if !ok {
+		return
+	}
+
+	future.info.ChannelId = e.ChannelId
+	future.info.UserId = e.UserId
+	future.info.SessionId = e.SessionId
+	future.receivedState = true
+
+	if future.complete() {
+		delete(s.voiceRequests, e.GuildId)
+		future.resolve(nil)
+	}
+}
+
+func resolveVoiceServer(s *Shard, e *events.VoiceServerUpdate) {
+	// A null endpoint means the voice server is unavailable, and Discord will send another update once one is allocated
+	if e.Endpoint == "" {
+		return
+	}
+
+	s.voiceRequestsLock.Lock()
+	defer s.voiceRequestsLock.Unlock()
+
+	future, ok := s.voiceRequests[e.GuildId]
+	if !ok {
+		return
+	}
+
+	future.info.Token = e.Token
+	future.info.Endpoint = e.Endpoint
+	future.receivedServer = true
+
+	if future.complete() {
+		delete(s.voiceRequests, e.GuildId)
+		future.resolve(nil)
+	}
+}
@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestUpdateVoiceStateFromListener(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		UserId: 5,
+		Guilds: []uint64{1},
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	// The voice updates are from the same guild as the message, so they would be queued behind this listener if the
+	// dispatcher delivered them
+	type result struct {
+		info VoiceConnectionInfo
+		err  error
+	}
+
+	results := make(chan result, 2)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		future, err := s.UpdateVoiceState(ctx, e.GuildId, e.ChannelId, false, true)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+
+		info, err := future.Wait(ctx)
+		results <- result{info: info, err: err}
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	waitForResult := func() result {
+		select {
+		case result := <-results:
+			return result
+		case <-time.After(time.Second * 10):
+			t.Fatal("timed out waiting for the voice connection future")
+			return result{}
+		}
+	}
+
+	require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{"id": "1", "guild_id": "1", "channel_id": "2"}))
+
+	joined := waitForResult()
+	require.NoError(t, joined.err)
+	require.Equal(t, VoiceConnectionInfo{
+		GuildId:   1,
+		ChannelId: 2,
+		UserId:    5,
+		SessionId: joined.info.SessionId,
+		Token:     "voice-token",
+		Endpoint:  "voice.gatewaytest",
+	}, joined.info)
+	require.NotEmpty(t, joined.info.SessionId)
+
+	// Leaving only waits for the VOICE_STATE_UPDATE
+	require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{"id": "2", "guild_id": "1"}))
+
+	left := waitForResult()
+	require.NoError(t, left.err)
+	require.Equal(t, uint64(0), left.info.ChannelId)
+
+	require.False(t, sm.Shards[0].pendingVoiceRequests())
+	require.Equal(t, uint64(5), sm.Shards[0].selfId.Load())
+}
+
+func TestVoiceConnectionFutureTimeout(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{Token: testToken})
+	sm := newTestShardManager(t, server, Hooks{})
+	s := sm.Shards[0]
+
+	// Neither future is sent, so they are never resolved
+	newFuture := func(guildId uint64) *VoiceConnectionFuture {
+		future := &VoiceConnectionFuture{
+			shard: s,
+			info:  VoiceConnectionInfo{GuildId: guildId},
+			done:  make(chan struct{}),
+		}
+
+		s.voiceRequestsLock.Lock()
+		s.voiceRequests[guildId] = future
+		s.voiceRequestsLock.Unlock()
+
+		return future
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	_, err := newFuture(1).Wait(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, s.pendingVoiceRequests())
+
+	// A future that has been superseded does not remove the newer one
+	superseded := newFuture(2)
+	newFuture(2)
+
+	_, err = superseded.Wait(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.True(t, s.pendingVoiceRequests())
+}
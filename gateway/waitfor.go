@@ -0,0 +1,206 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"sync"
+	"time"
+)
+
+// WaitFor blocks until any shard receives an event of type E that matches predicate, and returns it. A nil predicate
+// matches every event. If ctx is done first, ctx.Err() is returned, and if the shard manager is shut down first,
+// ErrShutdown is returned. The handler is removed before WaitFor returns.
+//
+// The event is shared with other listeners, and must not be modified.
+//
+// Events are matched as they are read from the gateway, before they are queued for the listeners, so WaitFor may be
+// called from a listener without blocking the event it is waiting for. As a result, the predicate is called on the
+// shard's read goroutine, and must not block, and events are matched before the middleware chain is run.
+func WaitFor[E events.Event](ctx context.Context, sm *ShardManager, predicate func(*Shard, *E) bool) (*E, error) {
+	ch := make(chan *E, 1)
+
+	var once sync.Once
+	unsubscribe := onRead(sm, func(s *Shard, e *E) {
+		if predicate == nil || predicate(s, e) {
+			once.Do(func() {
+				ch <- e
+			})
+		}
+	})
+	defer unsubscribe()
+
+	select {
+	case e := <-ch:
+		return e, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-sm.ctx.Done():
+		return nil, ErrShutdown
+	}
+}
+
+// onRead registers a handler that is called as events are read, rather than by the dispatcher
+func onRead[E events.Event](sm *ShardManager, handler func(*Shard, *E)) Unsubscribe {
+	sm.checkListenerIntents(events.TypeOf[E]())
+	return subscribe(sm.waiters, handler)
+}
+
+// StopReason is why a Collector stopped collecting events
+type StopReason int
+
+const (
+	StopReasonNone      StopReason = iota // the collector has not stopped yet
+	StopReasonMax                         // CollectorOptions.Max events were collected
+	StopReasonTimeout                     // CollectorOptions.Timeout elapsed
+	StopReasonCondition                   // CollectorOptions.StopWhen returned true
+	StopReasonStopped                     // Stop was called, or the context passed to Wait was done
+	StopReasonShutdown                    // the shard manager was shut down
+)
+
+type CollectorOptions[E events.Event] struct {
+	Filter   func(*Shard, *E) bool // events that do not match are ignored. nil collects every event
+	Max      int                   // stop once this many events have been collected. 0 for no limit
+	Timeout  time.Duration         // stop once this much time has passed since the collector was created. 0 for no timeout
+	StopWhen func(*Shard, *E) bool // called with each collected event, after it has been collected. stops if it returns true
+}
+
+// Collector gathers the events of type E received by any shard that match a filter, until it is stopped by one of its
+// options, by Stop, or by the shard manager shutting down. The handler is removed once the collector stops.
+//
+// As with WaitFor, events are collected as they are read, so Wait may be called from a listener. Filter and StopWhen
+// are called on the shard's read goroutine, and must not block.
+//
+// For example, to collect the reactions added to a poll for a minute:
+//
+//	collector := gateway.NewCollector(sm, gateway.CollectorOptions[events.MessageReactionAdd]{
+//		Filter: func(s *gateway.Shard, e *events.MessageReactionAdd) bool {
+//			return e.MessageId == pollMessageId
+//		},
+//		Timeout: time.Minute,
+//	})
+//
+//	reactions, _ := collector.Wait(ctx)
+type Collector[E events.Event] struct {
+	options CollectorOptions[E]
+
+	lock      sync.Mutex
+	collected []*E
+	reason    StopReason
+
+	done           chan struct{}
+	unsubscribe    Unsubscribe
+	timer          *time.Timer
+	stopOnShutdown func() bool
+}
+
+func NewCollector[E events.Event](sm *ShardManager, options CollectorOptions[E]) *Collector[E] {
+	c := &Collector[E]{
+		options: options,
+		done:    make(chan struct{}),
+	}
+
+	// Hold the lock whilst setting up, so that the collector cannot stop before it has been fully created
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.unsubscribe = onRead(sm, c.handle)
+	c.stopOnShutdown = context.AfterFunc(sm.ctx, func() {
+		c.stop(StopReasonShutdown)
+	})
+
+	if options.Timeout > 0 {
+		c.timer = time.AfterFunc(options.Timeout, func() {
+			c.stop(StopReasonTimeout)
+		})
+	}
+
+	return c
+}
+
+func (c *Collector[E]) handle(s *Shard, e *E) {
+	if c.options.Filter != nil && !c.options.Filter(s, e) {
+		return
+	}
+
+	c.lock.Lock()
+	if c.reason != StopReasonNone {
+		c.lock.Unlock()
+		return
+	}
+
+	c.collected = append(c.collected, e)
+
+	if c.options.Max > 0 && len(c.collected) >= c.options.Max {
+		c.stopLocked(StopReasonMax)
+		c.lock.Unlock()
+		return
+	}
+	c.lock.Unlock()
+
+	// StopWhen is called without the lock held, so that it may use the collector
+	if c.options.StopWhen != nil && c.options.StopWhen(s, e) {
+		c.stop(StopReasonCondition)
+	}
+}
+
+// Stop stops collecting events. It is safe to call more than once, and after the collector has stopped by itself.
+func (c *Collector[E]) Stop() {
+	c.stop(StopReasonStopped)
+}
+
+func (c *Collector[E]) stop(reason StopReason) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stopLocked(reason)
+}
+
+func (c *Collector[E]) stopLocked(reason StopReason) {
+	if c.reason != StopReasonNone {
+		return
+	}
+
+	c.reason = reason
+
+	c.unsubscribe()
+	c.stopOnShutdown()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+
+	close(c.done)
+}
+
+// Done returns a channel that is closed once the collector has stopped
+func (c *Collector[E]) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait blocks until the collector has stopped, and returns the collected events in the order they were collected. If
+// ctx is done first, the collector is stopped, and the events collected so far are returned with ctx.Err().
+func (c *Collector[E]) Wait(ctx context.Context) ([]*E, error) {
+	select {
+	case <-c.done:
+		return c.Events(), nil
+	case <-ctx.Done():
+		c.Stop()
+		return c.Events(), ctx.Err()
+	}
+}
+
+// Events returns a copy of the events collected so far
+func (c *Collector[E]) Events() []*E {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	collected := make([]*E, len(c.collected))
+	copy(collected, c.collected)
+	return collected
+}
+
+// Reason returns why the collector stopped, or StopReasonNone if it is still collecting
+func (c *Collector[E]) Reason() StopReason {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.reason
+}
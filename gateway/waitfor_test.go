@@ -0,0 +1,231 @@
+package gateway
+
+import (
+	"context"
+	"github.com/rxdn/gdl/gateway/gatewaytest"
+	"github.com/rxdn/gdl/gateway/payloads/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	type waitResult struct {
+		e   *events.MessageCreate
+		err error
+	}
+
+	result := make(chan waitResult, 1)
+	go func() {
+		e, err := WaitFor(ctx, sm, func(_ *Shard, e *events.MessageCreate) bool {
+			return e.Content == "yes"
+		})
+
+		result <- waitResult{e: e, err: err}
+	}()
+
+	// Wait for the handler to be registered
+	require.Eventually(t, func() bool {
+		return waiterCount[events.MessageCreate](sm) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{"id": "1", "content": "no"}))
+	require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{"id": "2", "content": "yes"}))
+
+	select {
+	case result := <-result:
+		require.NoError(t, result.err)
+		require.Equal(t, uint64(2), result.e.Id)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for WaitFor to return")
+	}
+
+	// The handler should have been removed
+	require.Equal(t, 0, waiterCount[events.MessageCreate](sm))
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancelExpired()
+
+	_, err := WaitFor[events.MessageCreate](expired, sm, nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitForFromListener(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		Guilds: []uint64{1},
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	// The reactions are from the same guild, so they would be queued behind this listener if WaitFor and the collector
+	// were passed events by the dispatcher
+	errs := make(chan error, 1)
+	On(sm, func(s *Shard, e *events.MessageCreate) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		if e.Content == "wait" {
+			_, err := WaitFor(ctx, sm, func(_ *Shard, reaction *events.MessageReactionAdd) bool {
+				return reaction.MessageId == e.Id
+			})
+
+			errs <- err
+		} else {
+			collector := NewCollector(sm, CollectorOptions[events.MessageReactionAdd]{
+				Filter: func(_ *Shard, reaction *events.MessageReactionAdd) bool {
+					return reaction.MessageId == e.Id
+				},
+				Max: 1,
+			})
+
+			_, err := collector.Wait(ctx)
+			errs <- err
+		}
+	})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	waitForError := func() error {
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(time.Second * 10):
+			t.Fatal("timed out waiting for the listener to return")
+			return nil
+		}
+	}
+
+	dispatch := func(messageId, content string) {
+		require.NoError(t, server.Dispatch("MESSAGE_CREATE", map[string]interface{}{
+			"id":       messageId,
+			"guild_id": "1",
+			"content":  content,
+		}))
+
+		// Wait for the handler to be registered
+		require.Eventually(t, func() bool {
+			return waiterCount[events.MessageReactionAdd](sm) == 1
+		}, time.Second*5, time.Millisecond)
+
+		require.NoError(t, server.Dispatch("MESSAGE_REACTION_ADD", map[string]interface{}{
+			"user_id":    "1",
+			"channel_id": "2",
+			"message_id": messageId,
+			"guild_id":   "1",
+			"emoji":      map[string]interface{}{"name": "👍"},
+		}))
+	}
+
+	dispatch("1", "wait")
+	require.NoError(t, waitForError())
+
+	dispatch("2", "collect")
+	require.NoError(t, waitForError())
+}
+
+func TestWaitForSyntheticEvent(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token:  testToken,
+		Guilds: []uint64{1},
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	result := make(chan *events.GuildAvailable, 1)
+	go func() {
+		e, _ := WaitFor[events.GuildAvailable](ctx, sm, nil)
+		result <- e
+	}()
+
+	require.Eventually(t, func() bool {
+		return waiterCount[events.GuildAvailable](sm) == 1
+	}, time.Second*5, time.Millisecond)
+
+	// The guild is classified as it is read, so a GUILD_CREATE straight after the outage is an available guild
+	require.NoError(t, server.Dispatch("GUILD_DELETE", map[string]interface{}{"id": "1", "unavailable": true}))
+	require.NoError(t, server.Dispatch("GUILD_CREATE", map[string]interface{}{"id": "1", "name": "Guild 1"}))
+
+	select {
+	case e := <-result:
+		require.NotNil(t, e)
+		require.Equal(t, uint64(1), e.Id)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for WaitFor to return")
+	}
+}
+
+func TestCollector(t *testing.T) {
+	server := newTestServer(t, gatewaytest.Options{
+		Token: testToken,
+	})
+	sm := newTestShardManager(t, server, Hooks{})
+
+	sm.Connect()
+	waitForReady(t, sm)
+
+	collector := NewCollector(sm, CollectorOptions[events.MessageReactionAdd]{
+		Filter: func(_ *Shard, e *events.MessageReactionAdd) bool {
+			return e.MessageId == 100
+		},
+		Max:     2,
+		Timeout: time.Second * 5,
+	})
+
+	for _, messageId := range []string{"100", "200", "100", "100"} {
+		require.NoError(t, server.Dispatch("MESSAGE_REACTION_ADD", map[string]interface{}{
+			"user_id":    "1",
+			"channel_id": "2",
+			"message_id": messageId,
+			"emoji":      map[string]interface{}{"name": "👍"},
+		}))
+	}
+
+	reactions, err := collector.Wait(context.Background())
+	require.NoError(t, err)
+	require.Len(t, reactions, 2)
+	require.Equal(t, StopReasonMax, collector.Reason())
+
+	for _, reaction := range reactions {
+		require.Equal(t, uint64(100), reaction.MessageId)
+	}
+
+	timedOut := NewCollector(sm, CollectorOptions[events.MessageReactionAdd]{
+		Filter: func(_ *Shard, e *events.MessageReactionAdd) bool {
+			return e.MessageId == 300
+		},
+		Timeout: time.Millisecond * 10,
+	})
+
+	reactions, err = timedOut.Wait(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, reactions)
+	require.Equal(t, StopReasonTimeout, timedOut.Reason())
+}
+
+func waiterCount[E events.Event](sm *ShardManager) int {
+	set, ok := sm.waiters.get(events.TypeOf[E]()).(*handlerSet[E])
+	if !ok {
+		return 0
+	}
+
+	set.RLock()
+	defer set.RUnlock()
+	return len(set.handlers)
+}
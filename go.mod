@@ -12,11 +12,11 @@ require (
 	github.com/jackc/pgx/v4 v4.18.3
 	github.com/json-iterator/go v1.1.10
 	github.com/juju/ratelimit v1.0.1
+	github.com/klauspost/compress v1.18.0
 	github.com/pasztorpisti/qs v0.0.0-20171216220353-8d6c33ee906c
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.5.0
 	github.com/stretchr/testify v1.8.1
-	github.com/tatsuworks/czlib v0.0.0-20190916144400-8a51758ea0d9
 	golang.org/x/exp v0.0.0-20241108190413-2d47ceb2692f
 	nhooyr.io/websocket v1.8.4
 )
@@ -32,7 +32,6 @@ require (
 	github.com/jackc/pgproto3/v2 v2.3.3 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
 	github.com/jackc/puddle v1.3.0 // indirect
-	github.com/klauspost/compress v1.10.3 // indirect
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
 	github.com/modern-go/reflect2 v0.0.0-20180701023420-4b7aa43c6742 // indirect
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"time"
+)
+
+// Metrics receives measurements from the gateway, REST client and cache. Implementations must be safe for
+// concurrent use.
+type Metrics interface {
+	EventReceived(shardId int, eventType string)
+	Reconnected(shardId int)
+	Identified(shardId int)
+	Resumed(shardId int)
+	HeartbeatLatency(shardId int, latency time.Duration)
+	HeartbeatMissed(shardId int) // A heartbeat was not acknowledged before the next was due
+
+	RestRequest(route ratelimit.RouteId, statusCode int, latency time.Duration) // statusCode is 0 if no response was received
+	RateLimitWait(route ratelimit.RouteId, wait time.Duration)
+
+	CacheAccess(object string, hit bool)
+}
+
+// Noop discards every measurement
+type Noop struct{}
+
+var _ Metrics = Noop{}
+
+func (Noop) EventReceived(int, string)                         {}
+func (Noop) Reconnected(int)                                   {}
+func (Noop) Identified(int)                                    {}
+func (Noop) Resumed(int)                                       {}
+func (Noop) HeartbeatLatency(int, time.Duration)               {}
+func (Noop) HeartbeatMissed(int)                               {}
+func (Noop) RestRequest(ratelimit.RouteId, int, time.Duration) {}
+func (Noop) RateLimitWait(ratelimit.RouteId, time.Duration)    {}
+func (Noop) CacheAccess(string, bool)                          {}
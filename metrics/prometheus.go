@@ -0,0 +1,275 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histograms
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Prometheus collects measurements in memory, and serves them in the Prometheus text exposition format
+type Prometheus struct {
+	namespace string
+
+	events           *counterVec
+	reconnects       *counterVec
+	sessions         *counterVec
+	heartbeatLatency *histogramVec
+	heartbeatsMissed *counterVec
+	restRequests     *counterVec
+	restLatency      *histogramVec
+	rateLimitWait    *histogramVec
+	cacheAccesses    *counterVec
+}
+
+var _ Metrics = (*Prometheus)(nil)
+
+func NewPrometheus(namespace string) *Prometheus {
+	return &Prometheus{
+		namespace:        namespace,
+		events:           newCounterVec("gateway_events_total", "Gateway events received", "shard", "event"),
+		reconnects:       newCounterVec("gateway_reconnects_total", "Gateway reconnections", "shard"),
+		sessions:         newCounterVec("gateway_sessions_total", "Sessions started by identifying or resuming", "shard", "type"),
+		heartbeatLatency: newHistogramVec("gateway_heartbeat_latency_seconds", "Heartbeat round trip time", DefaultBuckets, "shard"),
+		heartbeatsMissed: newCounterVec("gateway_heartbeats_missed_total", "Heartbeats that were not acknowledged", "shard"),
+		restRequests:     newCounterVec("rest_requests_total", "REST requests made", "route", "status"),
+		restLatency:      newHistogramVec("rest_request_duration_seconds", "REST request duration", DefaultBuckets, "route"),
+		rateLimitWait:    newHistogramVec("rest_ratelimit_wait_seconds", "Time spent waiting on rate limits before a REST request", DefaultBuckets, "route"),
+		cacheAccesses:    newCounterVec("cache_accesses_total", "Cache lookups", "object", "result"),
+	}
+}
+
+func (p *Prometheus) EventReceived(shardId int, eventType string) {
+	p.events.inc(strconv.Itoa(shardId), eventType)
+}
+
+func (p *Prometheus) Reconnected(shardId int) {
+	p.reconnects.inc(strconv.Itoa(shardId))
+}
+
+func (p *Prometheus) Identified(shardId int) {
+	p.sessions.inc(strconv.Itoa(shardId), "identify")
+}
+
+func (p *Prometheus) Resumed(shardId int) {
+	p.sessions.inc(strconv.Itoa(shardId), "resume")
+}
+
+func (p *Prometheus) HeartbeatLatency(shardId int, latency time.Duration) {
+	p.heartbeatLatency.observe(latency.Seconds(), strconv.Itoa(shardId))
+}
+
+func (p *Prometheus) HeartbeatMissed(shardId int) {
+	p.heartbeatsMissed.inc(strconv.Itoa(shardId))
+}
+
+func (p *Prometheus) RestRequest(route ratelimit.RouteId, statusCode int, latency time.Duration) {
+	routeLabel := route.String()
+	p.restRequests.inc(routeLabel, strconv.Itoa(statusCode))
+	p.restLatency.observe(latency.Seconds(), routeLabel)
+}
+
+func (p *Prometheus) RateLimitWait(route ratelimit.RouteId, wait time.Duration) {
+	p.rateLimitWait.observe(wait.Seconds(), route.String())
+}
+
+func (p *Prometheus) CacheAccess(object string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	p.cacheAccesses.inc(object, result)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+
+	prefix := ""
+	if p.namespace != "" {
+		prefix = p.namespace + "_"
+	}
+
+	p.events.write(buf, prefix)
+	p.reconnects.write(buf, prefix)
+	p.sessions.write(buf, prefix)
+	p.heartbeatLatency.write(buf, prefix)
+	p.heartbeatsMissed.write(buf, prefix)
+	p.restRequests.write(buf, prefix)
+	p.restLatency.write(buf, prefix)
+	p.rateLimitWait.write(buf, prefix)
+	p.cacheAccesses.write(buf, prefix)
+
+	err := buf.Flush()
+	return counter.n, err
+}
+
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = p.WriteTo(w)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type counterVec struct {
+	sync.Mutex
+	name, help string
+	labels     []string
+	values     map[string]uint64 // keyed by formatted label set
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]uint64),
+	}
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	key := formatLabels(c.labels, labelValues)
+
+	c.Lock()
+	c.values[key]++
+	c.Unlock()
+}
+
+func (c *counterVec) write(w io.Writer, prefix string) {
+	c.Lock()
+	defer c.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s%s %s\n", prefix, c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s%s counter\n", prefix, c.name)
+
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s%s %d\n", prefix, c.name, key, c.values[key])
+	}
+}
+
+type histogramVec struct {
+	sync.Mutex
+	name, help string
+	labels     []string
+	buckets    []float64
+	values     map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\x00")
+
+	h.Lock()
+	defer h.Unlock()
+
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = hist
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+			break
+		}
+	}
+
+	hist.count++
+	hist.sum += value
+}
+
+func (h *histogramVec) write(w io.Writer, prefix string) {
+	h.Lock()
+	defer h.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s%s %s\n", prefix, h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s%s histogram\n", prefix, h.name)
+
+	for _, key := range sortedKeys(h.values) {
+		hist := h.values[key]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			labels := formatLabels(with(h.labels, "le"), with(hist.labelValues, strconv.FormatFloat(bound, 'g', -1, 64)))
+			fmt.Fprintf(w, "%s%s_bucket%s %d\n", prefix, h.name, labels, cumulative)
+		}
+
+		labels := formatLabels(with(h.labels, "le"), with(hist.labelValues, "+Inf"))
+		fmt.Fprintf(w, "%s%s_bucket%s %d\n", prefix, h.name, labels, hist.count)
+
+		labels = formatLabels(h.labels, hist.labelValues)
+		fmt.Fprintf(w, "%s%s_sum%s %s\n", prefix, h.name, labels, strconv.FormatFloat(hist.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s%s_count%s %d\n", prefix, h.name, labels, hist.count)
+	}
+}
+
+// with returns a copy of values with value appended, leaving the original slice untouched
+func with(values []string, value string) []string {
+	copied := make([]string, len(values), len(values)+1)
+	copy(copied, values)
+	return append(copied, value)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%s", name, strconv.Quote(values[i]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
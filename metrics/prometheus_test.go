@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusCounters(t *testing.T) {
+	p := NewPrometheus("gdl")
+
+	p.EventReceived(0, "MESSAGE_CREATE")
+	p.EventReceived(0, "MESSAGE_CREATE")
+	p.EventReceived(1, "GUILD_CREATE")
+	p.Identified(0)
+	p.Resumed(1)
+	p.CacheAccess("guild", true)
+	p.CacheAccess("guild", false)
+
+	var buf bytes.Buffer
+	n, err := p.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+
+	output := buf.String()
+	require.Contains(t, output, "# TYPE gdl_gateway_events_total counter\n")
+	require.Contains(t, output, `gdl_gateway_events_total{shard="0",event="MESSAGE_CREATE"} 2`+"\n")
+	require.Contains(t, output, `gdl_gateway_events_total{shard="1",event="GUILD_CREATE"} 1`+"\n")
+	require.Contains(t, output, `gdl_gateway_sessions_total{shard="0",type="identify"} 1`+"\n")
+	require.Contains(t, output, `gdl_gateway_sessions_total{shard="1",type="resume"} 1`+"\n")
+	require.Contains(t, output, `gdl_cache_accesses_total{object="guild",result="hit"} 1`+"\n")
+	require.Contains(t, output, `gdl_cache_accesses_total{object="guild",result="miss"} 1`+"\n")
+}
+
+func TestPrometheusRestRoutes(t *testing.T) {
+	p := NewPrometheus("")
+
+	p.RestRequest(ratelimit.RouteCreateMessage, 200, time.Millisecond*20)
+	p.RestRequest(ratelimit.RouteCreateMessage, 429, time.Millisecond*20)
+	p.RateLimitWait(ratelimit.RouteGetGuild, 0)
+
+	var buf bytes.Buffer
+	_, err := p.WriteTo(&buf)
+	require.NoError(t, err)
+
+	// Routes are labelled by name, rather than by their numeric ID
+	output := buf.String()
+	require.Contains(t, output, `rest_requests_total{route="CreateMessage",status="200"} 1`+"\n")
+	require.Contains(t, output, `rest_requests_total{route="CreateMessage",status="429"} 1`+"\n")
+	require.Contains(t, output, `rest_request_duration_seconds_count{route="CreateMessage"} 2`+"\n")
+	require.Contains(t, output, `rest_ratelimit_wait_seconds_count{route="GetGuild"} 1`+"\n")
+}
+
+func TestPrometheusHistogram(t *testing.T) {
+	p := NewPrometheus("")
+
+	p.HeartbeatLatency(0, time.Millisecond*3)
+	p.HeartbeatLatency(0, time.Millisecond*40)
+	p.HeartbeatLatency(0, time.Second*20)
+
+	var buf bytes.Buffer
+	_, err := p.WriteTo(&buf)
+	require.NoError(t, err)
+
+	// Buckets are cumulative, and observations above the largest bound only appear in +Inf
+	output := buf.String()
+	require.Contains(t, output, `gateway_heartbeat_latency_seconds_bucket{shard="0",le="0.005"} 1`+"\n")
+	require.Contains(t, output, `gateway_heartbeat_latency_seconds_bucket{shard="0",le="0.025"} 1`+"\n")
+	require.Contains(t, output, `gateway_heartbeat_latency_seconds_bucket{shard="0",le="0.05"} 2`+"\n")
+	require.Contains(t, output, `gateway_heartbeat_latency_seconds_bucket{shard="0",le="10"} 2`+"\n")
+	require.Contains(t, output, `gateway_heartbeat_latency_seconds_bucket{shard="0",le="+Inf"} 3`+"\n")
+	require.Contains(t, output, `gateway_heartbeat_latency_seconds_sum{shard="0"} 20.043`+"\n")
+	require.Contains(t, output, `gateway_heartbeat_latency_seconds_count{shard="0"} 3`+"\n")
+}
+
+func TestPrometheusServeHTTP(t *testing.T) {
+	p := NewPrometheus("gdl")
+	p.Reconnected(2)
+
+	recorder := httptest.NewRecorder()
+	p.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+	require.Contains(t, recorder.Body.String(), `gdl_gateway_reconnects_total{shard="2"} 1`+"\n")
+}
@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"context"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/rxdn/gdl/rest/request"
+)
+
+type Gateway struct {
+	Url string `json:"url"`
+}
+
+type GatewayBot struct {
+	Url               string            `json:"url"`
+	Shards            int               `json:"shards"`
+	SessionStartLimit SessionStartLimit `json:"session_start_limit"`
+}
+
+type SessionStartLimit struct {
+	Total          int `json:"total"`
+	Remaining      int `json:"remaining"`
+	ResetAfter     int `json:"reset_after"` // Millis
+	MaxConcurrency int `json:"max_concurrency"`
+}
+
+func GetGateway(ctx context.Context) (Gateway, error) {
+	endpoint := request.Endpoint{
+		RequestType: request.GET,
+		ContentType: request.Nil,
+		Endpoint:    "/gateway",
+		Route:       ratelimit.NewOtherRoute(ratelimit.RouteGetGateway, 0),
+	}
+
+	var gateway Gateway
+	err, _ := endpoint.Request(ctx, "", nil, &gateway)
+	return gateway, err
+}
+
+func GetGatewayBot(ctx context.Context, token string, rateLimiter *ratelimit.Ratelimiter) (GatewayBot, error) {
+	endpoint := request.Endpoint{
+		RequestType: request.GET,
+		ContentType: request.Nil,
+		Endpoint:    "/gateway/bot",
+		Route:       ratelimit.NewOtherRoute(ratelimit.RouteGetGatewayBot, 0),
+		RateLimiter: rateLimiter,
+	}
+
+	var gateway GatewayBot
+	err, _ := endpoint.Request(ctx, token, nil, &gateway)
+	return gateway, err
+}
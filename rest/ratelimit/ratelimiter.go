@@ -10,9 +10,16 @@ import (
 type Ratelimiter struct {
 	sync.Mutex
 	Store                RateLimitStore
+	Metrics              Metrics // receives measurements of the requests made with this ratelimiter. nil to discard them
 	largeShardingBuckets int
 }
 
+// Metrics receives measurements of REST requests. It is implemented by metrics.Metrics.
+type Metrics interface {
+	RestRequest(route RouteId, statusCode int, latency time.Duration) // statusCode is 0 if no response was received
+	RateLimitWait(route RouteId, wait time.Duration)
+}
+
 func NewRateLimiter(store RateLimitStore, largeShardingBuckets int) *Ratelimiter {
 	return &Ratelimiter{
 		Store:                store,
@@ -0,0 +1,156 @@
+package ratelimit
+
+import "strconv"
+
+// routeNames are used as labels when reporting metrics, so must not change once added
+var routeNames = [...]string{
+	RouteGetGuildAuditLog:                  "GetGuildAuditLog",
+	RouteGetChannel:                        "GetChannel",
+	RouteModifyChannel:                     "ModifyChannel",
+	RouteDeleteChannel:                     "DeleteChannel",
+	RouteGetChannelMessages:                "GetChannelMessages",
+	RouteGetChannelMessage:                 "GetChannelMessage",
+	RouteCreateMessage:                     "CreateMessage",
+	RouteCrosspostMessage:                  "CrosspostMessage",
+	RouteCreateReaction:                    "CreateReaction",
+	RouteDeleteOwnReaction:                 "DeleteOwnReaction",
+	RouteDeleteUserReaction:                "DeleteUserReaction",
+	RouteGetReactions:                      "GetReactions",
+	RouteDeleteAllReactions:                "DeleteAllReactions",
+	RouteDeleteAllReactionsForEmoji:        "DeleteAllReactionsForEmoji",
+	RouteEditMessage:                       "EditMessage",
+	RouteDeleteMessage:                     "DeleteMessage",
+	RouteBulkDeleteMessages:                "BulkDeleteMessages",
+	RouteEditChannelPermissions:            "EditChannelPermissions",
+	RouteGetChannelInvites:                 "GetChannelInvites",
+	RouteCreateChannelInvite:               "CreateChannelInvite",
+	RouteDeleteChannelPermission:           "DeleteChannelPermission",
+	RouteFollowNewsChannel:                 "FollowNewsChannel",
+	RouteTriggerTypingIndicator:            "TriggerTypingIndicator",
+	RouteGetPinnedMessages:                 "GetPinnedMessages",
+	RouteAddPinnedChannelMessage:           "AddPinnedChannelMessage",
+	RouteDeletePinnedChannelMessage:        "DeletePinnedChannelMessage",
+	RouteJoinThread:                        "JoinThread",
+	RouteAddThreadMember:                   "AddThreadMember",
+	RouteLeaveThread:                       "LeaveThread",
+	RouteRemoveThreadMember:                "RemoveThreadMember",
+	RouteListThreadMembers:                 "ListThreadMembers",
+	RouteGetThreadMember:                   "GetThreadMember",
+	RouteStartThreadWithMessage:            "StartThreadWithMessage",
+	RouteStartThreadWithoutMessage:         "StartThreadWithoutMessage",
+	RouteGetActiveThreads:                  "GetActiveThreads",
+	RouteGetArchivedPrivateSelfThreads:     "GetArchivedPrivateSelfThreads",
+	RouteGetArchivedPublicThreads:          "GetArchivedPublicThreads",
+	RouteGetArchivedPrivateThreads:         "GetArchivedPrivateThreads",
+	RouteGroupDMAddRecipient:               "GroupDMAddRecipient",
+	RouteGroupDMRemoveRecipient:            "GroupDMRemoveRecipient",
+	RouteListGuildEmojis:                   "ListGuildEmojis",
+	RouteGetGuildEmoji:                     "GetGuildEmoji",
+	RouteCreateGuildEmoji:                  "CreateGuildEmoji",
+	RouteModifyGuildEmoji:                  "ModifyGuildEmoji",
+	RouteDeleteGuildEmoji:                  "DeleteGuildEmoji",
+	RouteCreateGuild:                       "CreateGuild",
+	RouteGetGuild:                          "GetGuild",
+	RouteGetGuildPreview:                   "GetGuildPreview",
+	RouteModifyGuild:                       "ModifyGuild",
+	RouteDeleteGuild:                       "DeleteGuild",
+	RouteGetGuildChannels:                  "GetGuildChannels",
+	RouteCreateGuildChannel:                "CreateGuildChannel",
+	RouteModifyGuildChannelPositions:       "ModifyGuildChannelPositions",
+	RouteGetGuildMember:                    "GetGuildMember",
+	RouteSearchGuildMembers:                "SearchGuildMembers",
+	RouteListGuildMembers:                  "ListGuildMembers",
+	RouteAddGuildMember:                    "AddGuildMember",
+	RouteModifyGuildMember:                 "ModifyGuildMember",
+	RouteModifyCurrentUserNick:             "ModifyCurrentUserNick",
+	RouteAddGuildMemberRole:                "AddGuildMemberRole",
+	RouteRemoveGuildMemberRole:             "RemoveGuildMemberRole",
+	RouteRemoveGuildMember:                 "RemoveGuildMember",
+	RouteGetGuildBans:                      "GetGuildBans",
+	RouteGetGuildBan:                       "GetGuildBan",
+	RouteCreateGuildBan:                    "CreateGuildBan",
+	RouteRemoveGuildBan:                    "RemoveGuildBan",
+	RouteGetGuildRoles:                     "GetGuildRoles",
+	RouteCreateGuildRole:                   "CreateGuildRole",
+	RouteModifyGuildRolePositions:          "ModifyGuildRolePositions",
+	RouteModifyGuildRole:                   "ModifyGuildRole",
+	RouteDeleteGuildRole:                   "DeleteGuildRole",
+	RouteGetGuildPruneCount:                "GetGuildPruneCount",
+	RouteBeginGuildPrune:                   "BeginGuildPrune",
+	RouteGetGuildVoiceRegions:              "GetGuildVoiceRegions",
+	RouteGetGuildInvites:                   "GetGuildInvites",
+	RouteGetGuildIntegrations:              "GetGuildIntegrations",
+	RouteCreateGuildIntegration:            "CreateGuildIntegration",
+	RouteModifyGuildIntegration:            "ModifyGuildIntegration",
+	RouteDeleteGuildIntegration:            "DeleteGuildIntegration",
+	RouteSyncGuildIntegration:              "SyncGuildIntegration",
+	RouteGetGuildWidgetSettings:            "GetGuildWidgetSettings",
+	RouteModifyGuildWidget:                 "ModifyGuildWidget",
+	RouteGetGuildWidget:                    "GetGuildWidget",
+	RouteGetGuildVanityURL:                 "GetGuildVanityURL",
+	RouteGuildWidgetImage:                  "GuildWidgetImage",
+	RouteGetInvite:                         "GetInvite",
+	RouteDeleteInvite:                      "DeleteInvite",
+	RouteGetTemplate:                       "GetTemplate",
+	RouteCreateGuildTemplate:               "CreateGuildTemplate",
+	RouteGetCurrentUser:                    "GetCurrentUser",
+	RouteGetUser:                           "GetUser",
+	RouteModifyCurrentUser:                 "ModifyCurrentUser",
+	RouteGetCurrentUserGuilds:              "GetCurrentUserGuilds",
+	RouteLeaveGuild:                        "LeaveGuild",
+	RouteGetUserDMs:                        "GetUserDMs",
+	RouteCreateDM:                          "CreateDM",
+	RouteCreateGroupDM:                     "CreateGroupDM",
+	RouteGetUserConnections:                "GetUserConnections",
+	RouteListVoiceRegions:                  "ListVoiceRegions",
+	RouteCreateWebhook:                     "CreateWebhook",
+	RouteGetChannelWebhooks:                "GetChannelWebhooks",
+	RouteGetGuildWebhooks:                  "GetGuildWebhooks",
+	RouteGetWebhook:                        "GetWebhook",
+	RouteGetWebhookWithToken:               "GetWebhookWithToken",
+	RouteModifyWebhook:                     "ModifyWebhook",
+	RouteModifyWebhookWithToken:            "ModifyWebhookWithToken",
+	RouteDeleteWebhook:                     "DeleteWebhook",
+	RouteDeleteWebhookWithToken:            "DeleteWebhookWithToken",
+	RouteExecuteWebhook:                    "ExecuteWebhook",
+	RouteEditWebhookMessage:                "EditWebhookMessage",
+	RouteGetGlobalCommands:                 "GetGlobalCommands",
+	RouteCreateGlobalCommand:               "CreateGlobalCommand",
+	RouteModifyGlobalCommand:               "ModifyGlobalCommand",
+	RouteModifyGlobalCommands:              "ModifyGlobalCommands",
+	RouteDeleteGlobalCommand:               "DeleteGlobalCommand",
+	RouteGetGuildCommands:                  "GetGuildCommands",
+	RouteCreateGuildCommand:                "CreateGuildCommand",
+	RouteModifyGuildCommand:                "ModifyGuildCommand",
+	RouteModifyGuildCommands:               "ModifyGuildCommands",
+	RouteDeleteGuildCommand:                "DeleteGuildCommand",
+	RouteGetCommandPermissions:             "GetCommandPermissions",
+	RouteGetBulkCommandPermissions:         "GetBulkCommandPermissions",
+	RouteEditCommandPermissions:            "EditCommandPermissions",
+	RouteEditBulkCommandPermissions:        "EditBulkCommandPermissions",
+	RouteGetOriginalInteractionResponse:    "GetOriginalInteractionResponse",
+	RouteEditOriginalInteractionResponse:   "EditOriginalInteractionResponse",
+	RouteDeleteOriginalInteractionResponse: "DeleteOriginalInteractionResponse",
+	RouteCreateFollowupMessage:             "CreateFollowupMessage",
+	RouteGetFollowupMessage:                "GetFollowupMessage",
+	RouteEditFollowupMessage:               "EditFollowupMessage",
+	RouteDeleteFollowupMessage:             "DeleteFollowupMessage",
+	RouteGetCurrentApplication:             "GetCurrentApplication",
+	RouteEditCurrentApplication:            "EditCurrentApplication",
+	RouteListEntitlements:                  "ListEntitlements",
+	RouteConsumeEntitlement:                "ConsumeEntitlement",
+	RouteCreateTestEntitlement:             "CreateTestEntitlement",
+	RouteDeleteTestEntitlement:             "DeleteTestEntitlement",
+	RouteOauth2TokenExchange:               "Oauth2TokenExchange",
+	RouteOauth2TokenRevoke:                 "Oauth2TokenRevoke",
+	RouteGetGateway:                        "GetGateway",
+	RouteGetGatewayBot:                     "GetGatewayBot",
+}
+
+func (r RouteId) String() string {
+	if int(r) < len(routeNames) {
+		return routeNames[r]
+	}
+
+	return "Route(" + strconv.Itoa(int(r)) + ")"
+}
@@ -0,0 +1,13 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRouteNames(t *testing.T) {
+	require.Equal(t, "GetGuildAuditLog", RouteGetGuildAuditLog.String())
+	require.Equal(t, "CreateMessage", RouteCreateMessage.String())
+	require.Equal(t, "GetGatewayBot", RouteGetGatewayBot.String())
+	require.Equal(t, "Route(65535)", RouteId(65535).String())
+}
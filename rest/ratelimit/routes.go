@@ -224,4 +224,8 @@ const (
 	// /oauth2/
 	RouteOauth2TokenExchange
 	RouteOauth2TokenRevoke
+
+	// /gateway
+	RouteGetGateway
+	RouteGetGatewayBot
 )
@@ -62,6 +62,12 @@ func RegisterPostRequestHook(hook func(*http.Response, []byte)) {
 	postRequestHooksMu.Unlock()
 }
 
+// noMetrics discards the measurements of requests made without a ratelimiter, or with one that has no Metrics
+type noMetrics struct{}
+
+func (noMetrics) RestRequest(ratelimit.RouteId, int, time.Duration) {}
+func (noMetrics) RateLimitWait(ratelimit.RouteId, time.Duration)    {}
+
 // TODO: Allow users to specify custom timeouts
 var Client = http.Client{
 	Transport: &http.Transport{
@@ -73,15 +79,23 @@ var Client = http.Client{
 func (e *Endpoint) Request(ctx context.Context, token string, body any, response any) (error, *ResponseWithContent) {
 	url := BaseUrl + e.Endpoint
 
+	var m ratelimit.Metrics = noMetrics{}
+	if e.RateLimiter != nil && e.RateLimiter.Metrics != nil {
+		m = e.RateLimiter.Metrics
+	}
+
 	// Ratelimit
 	if e.RateLimiter != nil {
 		ch := make(chan error)
 		go e.RateLimiter.ExecuteCall(e.Route, ch)
 
+		waitStart := time.Now()
 		select {
 		case <-ctx.Done():
+			m.RateLimitWait(e.Route.Id, time.Since(waitStart))
 			return errors.Wrap(ctx.Err(), "context deadline exceeded while waiting for ratelimit"), nil
 		case err := <-ch:
+			m.RateLimitWait(e.Route.Id, time.Since(waitStart))
 			if err != nil {
 				return err, nil
 			}
@@ -164,10 +178,14 @@ func (e *Endpoint) Request(ctx context.Context, token string, body any, response
 		executePostRequestHooks(res, content)
 	}()
 
+	requestStart := time.Now()
 	res, err = Client.Do(req)
 	if err != nil {
+		m.RestRequest(e.Route.Id, 0, time.Since(requestStart))
 		return err, nil
 	}
+
+	m.RestRequest(e.Route.Id, res.StatusCode, time.Since(requestStart))
 	defer res.Body.Close()
 
 	if e.RateLimiter != nil {
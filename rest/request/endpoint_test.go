@@ -0,0 +1,89 @@
+package request
+
+import (
+	"context"
+	"github.com/rxdn/gdl/rest/ratelimit"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type recordedRequest struct {
+	route      ratelimit.RouteId
+	statusCode int
+}
+
+type recordingMetrics struct {
+	sync.Mutex
+	requests []recordedRequest
+	waits    []ratelimit.RouteId
+}
+
+func (m *recordingMetrics) RestRequest(route ratelimit.RouteId, statusCode int, _ time.Duration) {
+	m.Lock()
+	m.requests = append(m.requests, recordedRequest{route: route, statusCode: statusCode})
+	m.Unlock()
+}
+
+func (m *recordingMetrics) RateLimitWait(route ratelimit.RouteId, _ time.Duration) {
+	m.Lock()
+	m.waits = append(m.waits, route)
+	m.Unlock()
+}
+
+func TestRequestMetricsPerRatelimiter(t *testing.T) {
+	transport := Client.Transport
+	Client.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+			Request:    req,
+		}, nil
+	})
+	t.Cleanup(func() {
+		Client.Transport = transport
+	})
+
+	// Each ratelimiter reports to its own metrics, as each shard manager has its own ratelimiter
+	first, second := &recordingMetrics{}, &recordingMetrics{}
+
+	firstLimiter := ratelimit.NewRateLimiter(ratelimit.NewMemoryStore(), 1)
+	firstLimiter.Metrics = first
+
+	secondLimiter := ratelimit.NewRateLimiter(ratelimit.NewMemoryStore(), 1)
+	secondLimiter.Metrics = second
+
+	request := func(rateLimiter *ratelimit.Ratelimiter, routeId ratelimit.RouteId) {
+		endpoint := Endpoint{
+			RequestType: GET,
+			ContentType: Nil,
+			Endpoint:    "/test",
+			Route:       ratelimit.NewOtherRoute(routeId, 0),
+			RateLimiter: rateLimiter,
+		}
+
+		err, _ := endpoint.Request(context.Background(), "token", nil, nil)
+		require.NoError(t, err)
+	}
+
+	request(firstLimiter, ratelimit.RouteGetChannel)
+	request(secondLimiter, ratelimit.RouteGetGuild)
+	request(nil, ratelimit.RouteGetUser)
+
+	require.Equal(t, []recordedRequest{{route: ratelimit.RouteGetChannel, statusCode: http.StatusOK}}, first.requests)
+	require.Equal(t, []ratelimit.RouteId{ratelimit.RouteGetChannel}, first.waits)
+
+	require.Equal(t, []recordedRequest{{route: ratelimit.RouteGetGuild, statusCode: http.StatusOK}}, second.requests)
+	require.Equal(t, []ratelimit.RouteId{ratelimit.RouteGetGuild}, second.waits)
+}